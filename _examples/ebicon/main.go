@@ -62,10 +62,10 @@ func (g *game) Update() error {
 
 	// If enter is pressed, process the command
 	if repeatingKeyPressed(ebiten.KeyEnter) || repeatingKeyPressed(ebiten.KeyKPEnter) {
-		cv, err := g.console.ExecCmd(g.command)
+		cv, ok, err := g.console.ExecVar(g.command)
 		if err != nil {
 			g.console.LogErrorf("%s -> %s", g.command, err)
-		} else if !cv.Func() {
+		} else if ok && !cv.IsFunc() {
 			v, _ := cv.Interface()
 			g.console.LogInfof("%s %v", cv.Name(), v)
 		}
@@ -0,0 +1,126 @@
+// Copyright © 2020 Cosku Bas. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package convar
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+const (
+	maxInt = int(^uint(0) >> 1)
+	minInt = -maxInt - 1
+)
+
+// DefaultAddVarFunc is the callback of the addvar command: addvar <convar> <amount>
+// The amount is added to the current value of an int or float64 convar. Int results that overflow are rejected.
+func DefaultAddVarFunc(ctx context.Context, con *Console, oldVal, newVal interface{}) {
+	if err := con.adjustVar(ctx, "addvar", newVal.(string), false); err != nil {
+		con.LogErrorf("%v", err)
+	}
+}
+
+// DefaultScaleVarFunc is the callback of the scalevar command: scalevar <convar> <factor>
+// The current value of an int or float64 convar is multiplied by the factor, int results are rounded
+// and rejected if they overflow.
+func DefaultScaleVarFunc(ctx context.Context, con *Console, oldVal, newVal interface{}) {
+	if err := con.adjustVar(ctx, "scalevar", newVal.(string), true); err != nil {
+		con.LogErrorf("%v", err)
+	}
+}
+
+// adjustVar changes the value of a numeric convar relative to its current value, clamped to its range if it has one.
+// The new value is set like a typed setter does, triggering the callback. cmd is the name of the command,
+// whose usage is included in the error of a wrong number of arguments.
+func (c *Console) adjustVar(ctx context.Context, cmd, args string, scale bool) error {
+	var (
+		parts [2]string
+		rest  = args
+		err   error
+	)
+	for i := range parts {
+		if parts[i], rest, err = nextToken(rest); err != nil {
+			return c.tokenErr(err)
+		}
+	}
+	varName, valStr := parts[0], parts[1]
+	if valStr == "" || rest != "" {
+		return c.usageErr(cmd)
+	}
+
+	cv := c.ConVar(varName)
+	if cv == nil {
+		return fmt.Errorf(c.str().ErrVarNotFound, varName)
+	}
+	if cv.isFunc || (cv.varType != reflect.Int && cv.varType != reflect.Float64) {
+		// Func convars don't hold a value to adjust
+		return fmt.Errorf(c.str().ErrNotNumeric, cv.varName)
+	}
+	if !stateFrom(ctx).allows(cv) {
+		return fmt.Errorf(c.str().ErrNoPrivilege, cv.varName)
+	}
+
+	// The amount has the type of the convar while the factor is always a float
+	kind := cv.varType
+	if scale {
+		kind = reflect.Float64
+	}
+	parse := c.parser(kind)
+	if parse == nil {
+		return fmt.Errorf(c.str().ErrUnsupportedType, kind)
+	}
+	operand, err := parse(valStr)
+	if err != nil || reflect.TypeOf(operand).Kind() != kind {
+		return fmt.Errorf(c.str().ErrBadStringConversion, valStr, kind)
+	}
+
+	var value interface{}
+	switch current := cv.load().(type) {
+	case int:
+		// Int results that don't fit are rejected instead of wrapping around
+		if scale {
+			product := math.Round(float64(current) * operand.(float64))
+			if math.IsNaN(product) || product < float64(minInt) || product >= -float64(minInt) {
+				return fmt.Errorf(c.str().ErrNumRange, strconv.FormatFloat(product, 'g', -1, 64), cv.varType)
+			}
+			value = int(product)
+		} else {
+			amount := operand.(int)
+			if (amount > 0 && current > maxInt-amount) || (amount < 0 && current < minInt-amount) {
+				sum := float64(current) + float64(amount)
+				return fmt.Errorf(c.str().ErrNumRange, strconv.FormatFloat(sum, 'g', -1, 64), cv.varType)
+			}
+			value = current + amount
+		}
+	case float64:
+		if scale {
+			value = current * operand.(float64)
+		} else {
+			value = current + operand.(float64)
+		}
+	}
+	if min, max, ok := cv.Range(); ok {
+		if compare(value, min) < 0 {
+			value = min
+		} else if compare(value, max) > 0 {
+			value = max
+		}
+	}
+	_, err = cv.apply(ctx, cv.varType, value)
+	return err
+}
+
+// usageErr returns the error of a command executed with a wrong number of arguments,
+// followed by the usage of the command if it has one.
+func (c *Console) usageErr(cmd string) error {
+	err := fmt.Errorf(c.str().ErrBadArgs, cmd)
+	if cv := c.ConVar(cmd); cv != nil && cv.Usage() != "" {
+		return fmt.Errorf("%v, "+c.str().MsgUsage, err, cv.Usage())
+	}
+	return err
+}
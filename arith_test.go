@@ -0,0 +1,96 @@
+// Copyright © 2020 Cosku Bas. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package convar
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAddScaleVar(t *testing.T) {
+	c := NewConsole(100, LogError, "", "", "")
+	c.RegDefaultConVars()
+	width := NewConVar("cl_width", reflect.Int, false, "", 640, nil)
+	volume := NewConVar("volume", reflect.Float64, false, "", 0.5, nil)
+	fov := NewConVar("fov", reflect.Int, false, "", 90, nil)
+	c.RegConVar(width)
+	c.RegConVar(volume)
+	c.RegConVar(fov)
+	if err := fov.SetRange(60, 120); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, step := range []struct {
+		cmd  string
+		cv   *ConVar
+		want interface{}
+	}{
+		{"addvar cl_width 160", width, 800},
+		{"addvar cl_width -1000", width, -200},
+		{"scalevar cl_width -2.5", width, 500},
+		{"scalevar cl_width 0.001", width, 1},
+		{"scalevar cl_width 0.5", width, 1}, // 0.5 is rounded away from zero
+		{"addvar volume 0.25", volume, 0.75},
+		{"scalevar volume 2", volume, 1.5},
+		{"addvar fov 100", fov, 120},
+		{"scalevar fov 0", fov, 60},
+	} {
+		if _, err := c.ExecCmd(step.cmd); err != nil {
+			t.Fatalf("%q: %v", step.cmd, err)
+		}
+		if got := step.cv.get(); got != step.want {
+			t.Errorf("%q: %s = %v, want %v", step.cmd, step.cv.Name(), got, step.want)
+		}
+	}
+}
+
+func TestAddScaleVarErrors(t *testing.T) {
+	c := NewConsole(100, LogError, "", "", "")
+	c.RegDefaultConVars()
+	width := NewConVar("cl_width", reflect.Int, false, "", 640, nil)
+	c.RegConVar(width)
+	c.RegConVar(NewConVar("name", reflect.String, false, "", "", nil))
+
+	max := strconv.Itoa(maxInt)
+	for _, step := range []struct {
+		value int
+		cmd   string
+		want  string
+	}{
+		{640, "addvar", "wrong number of arguments for addvar, usage: addvar <convar> <amount>"},
+		{640, "addvar cl_width", "wrong number of arguments for addvar, usage: addvar <convar> <amount>"},
+		{640, "scalevar cl_width 2 3", "wrong number of arguments for scalevar, usage: scalevar <convar> <factor>"},
+		{640, "addvar cl_width abc", "can't convert value abc from string to int"},
+		{640, "addvar cl_height 1", "variable cl_height doesn't exist"},
+		{640, "addvar name 1", "variable name is not numeric"},
+		{640, "addvar cl_width " + max, "is out of the range of type int"},
+		{640, "scalevar cl_width " + max, "is out of the range of type int"},
+		{640, "scalevar cl_width nan", "is out of the range of type int"},
+		{-640, "addvar cl_width -" + max, "is out of the range of type int"},
+		{maxInt, "addvar cl_width 1", "is out of the range of type int"},
+		{minInt, "addvar cl_width -1", "is out of the range of type int"},
+		{minInt, "scalevar cl_width -1", "is out of the range of type int"},
+	} {
+		width.SetInt(step.value)
+		c.ClearBuffer()
+		c.ExecCmd(step.cmd)
+		lines := c.BufferRaw()
+		if len(lines) != 1 || !strings.Contains(lines[0], step.want) {
+			t.Errorf("%q logs %q, want %q", step.cmd, lines, step.want)
+		}
+		if got := width.MustInt(); got != step.value {
+			t.Errorf("%q changes cl_width to %d", step.cmd, got)
+		}
+	}
+
+	// The bounds themselves are still reachable
+	width.SetInt(maxInt - 1)
+	c.ExecCmd("addvar cl_width 1")
+	if got := width.MustInt(); got != maxInt {
+		t.Errorf("cl_width = %d, want %d", got, maxInt)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright © 2020 Cosku Bas. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package convar
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// RegStruct registers a convar for each field of the struct pointed by ptr that has a `convar:"name,desc"` tag.
+// The current value of a field becomes the default value of its convar and the convar writes its value back
+// into the field whenever it's changed. Fields of type int, float64, string and bool are supported, where
+// bool fields are registered as integer convars. Fields tagged with "-" are skipped.
+//
+// If any of the tagged fields is invalid, an error is returned and no convars are registered.
+// Note that the fields are written from within the convar callbacks without any synchronization.
+func (c *Console) RegStruct(ptr interface{}) error {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf(c.str().ErrNotStructPtr, ptr)
+	}
+	v = v.Elem()
+	t := v.Type()
+
+	var cvs []*ConVar
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("convar")
+		if !ok || tag == "-" {
+			continue
+		}
+		name, desc := tag, ""
+		if n := strings.IndexByte(tag, ','); n >= 0 {
+			name, desc = tag[:n], tag[n+1:]
+		}
+		if !validName(name) {
+			return fmt.Errorf(c.str().ErrInvalidName, name)
+		}
+		if sf.PkgPath != "" {
+			return fmt.Errorf(c.str().ErrFieldUnexported, sf.Name)
+		}
+
+		field := v.Field(i)
+		var (
+			kind       reflect.Kind
+			valDefault interface{}
+			valSet     ValSetFunc
+		)
+		switch field.Kind() {
+		case reflect.Int:
+			kind, valDefault = reflect.Int, int(field.Int())
+			valSet = func(con *Console, oldVal, newVal interface{}) {
+				field.SetInt(int64(newVal.(int)))
+			}
+		case reflect.Bool:
+			kind, valDefault = reflect.Int, 0
+			if field.Bool() {
+				valDefault = 1
+			}
+			valSet = func(con *Console, oldVal, newVal interface{}) {
+				field.SetBool(newVal.(int) == 1)
+			}
+		case reflect.Float64:
+			kind, valDefault = reflect.Float64, field.Float()
+			valSet = func(con *Console, oldVal, newVal interface{}) {
+				field.SetFloat(newVal.(float64))
+			}
+		case reflect.String:
+			kind, valDefault = reflect.String, field.String()
+			valSet = func(con *Console, oldVal, newVal interface{}) {
+				field.SetString(newVal.(string))
+			}
+		default:
+			return fmt.Errorf(c.str().ErrFieldUnsupported, sf.Name, field.Kind())
+		}
+		cvs = append(cvs, NewConVar(name, kind, false, desc, valDefault, valSet))
+	}
+
+	for _, cv := range cvs {
+		c.RegConVar(cv)
+	}
+	return nil
+}
+
+// BindInt registers an integer convar that writes its value into *ptr whenever it's changed.
+// The current value of *ptr becomes the default value. valSet is called after *ptr is updated and can be nil.
+// This lets hot code paths read a plain variable while the console is in control of it.
+// Note that *ptr is written from within the convar callback without any synchronization.
+func (c *Console) BindInt(varName, varDesc string, ptr *int, valSet ValSetFunc) *ConVar {
+	cv := NewConVar(varName, reflect.Int, false, varDesc, *ptr, func(con *Console, oldVal, newVal interface{}) {
+		*ptr = newVal.(int)
+		if valSet != nil {
+			valSet(con, oldVal, newVal)
+		}
+	})
+	c.RegConVar(cv)
+	return cv
+}
+
+// BindBool registers an integer convar that writes its value into *ptr as a boolean whenever it's changed.
+// See BindInt for details.
+func (c *Console) BindBool(varName, varDesc string, ptr *bool, valSet ValSetFunc) *ConVar {
+	valDefault := 0
+	if *ptr {
+		valDefault = 1
+	}
+	cv := NewConVar(varName, reflect.Int, false, varDesc, valDefault, func(con *Console, oldVal, newVal interface{}) {
+		*ptr = newVal.(int) == 1
+		if valSet != nil {
+			valSet(con, oldVal, newVal)
+		}
+	})
+	c.RegConVar(cv)
+	return cv
+}
+
+// BindFloat64 registers a float64 convar that writes its value into *ptr whenever it's changed.
+// See BindInt for details.
+func (c *Console) BindFloat64(varName, varDesc string, ptr *float64, valSet ValSetFunc) *ConVar {
+	cv := NewConVar(varName, reflect.Float64, false, varDesc, *ptr, func(con *Console, oldVal, newVal interface{}) {
+		*ptr = newVal.(float64)
+		if valSet != nil {
+			valSet(con, oldVal, newVal)
+		}
+	})
+	c.RegConVar(cv)
+	return cv
+}
+
+// BindString registers a string convar that writes its value into *ptr whenever it's changed.
+// See BindInt for details.
+func (c *Console) BindString(varName, varDesc string, ptr *string, valSet ValSetFunc) *ConVar {
+	cv := NewConVar(varName, reflect.String, false, varDesc, *ptr, func(con *Console, oldVal, newVal interface{}) {
+		*ptr = newVal.(string)
+		if valSet != nil {
+			valSet(con, oldVal, newVal)
+		}
+	})
+	c.RegConVar(cv)
+	return cv
+}
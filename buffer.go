@@ -5,11 +5,11 @@
 package convar
 
 import (
+	"bytes"
 	"fmt"
-	"io/ioutil"
-	"os"
 	"strings"
 	"sync/atomic"
+	"unicode"
 )
 
 // LogLevel is the type for the log level.
@@ -26,14 +26,124 @@ const (
 	LogError
 )
 
-func (c *Console) log(prefix, format string, a ...interface{}) {
+// LogFunc is the function signature of a log hook. level is the level of the message, which is LogNone
+// for the messages printed via LogPrintf. The line written to the buffer is prefix followed by msg.
+type LogFunc func(level LogLevel, prefix, msg string)
+
+type logHook struct {
+	fn     LogFunc
+	mirror bool // Forwards to another console, see Mirror
+}
+
+type clearHook struct {
+	fn func()
+}
+
+func (c *Console) log(level LogLevel, prefix, format string, a ...interface{}) {
+	if c.discard {
+		return
+	}
+	c.write(level, prefix, fmt.Sprintf(format, a...), false)
+}
+
+// write writes the message to the buffer and calls the log hooks. If mirrored is true, the message is forwarded
+// from another console by Mirror, and it isn't forwarded again so that consoles can mirror each other.
+func (c *Console) write(level LogLevel, prefix, msg string, mirrored bool) {
 	c.bufLock.Lock()
-	defer c.bufLock.Unlock()
-	if len(c.buffer) >= c.bufMaxLines {
-		c.buffer = c.buffer[1:]
+	if n := len(c.buffer); c.bufMaxLines > 0 && n >= c.bufMaxLines {
+		// The buffer is a ring once it's full, the oldest line is overwritten without any allocation
+		c.buffer[c.bufHead] = prefix + msg
+		c.bufLevels[c.bufHead] = level
+		c.bufHead = (c.bufHead + 1) % n
+	} else {
+		c.buffer = append(c.buffer, prefix+msg)
+		c.bufLevels = append(c.bufLevels, level)
+	}
+	c.bufLock.Unlock()
+
+	// Hooks are called without holding any locks so that they can use the console freely
+	c.hookLock.RLock()
+	hooks := c.logHooks
+	c.hookLock.RUnlock()
+	for _, h := range hooks {
+		if !mirrored || !h.mirror {
+			h.fn(level, prefix, msg)
+		}
+	}
+}
+
+// OnLog registers a hook that is called with every message written to the console buffer, after it's written.
+// Multiple hooks can be registered. Calling the returned function removes the hook.
+func (c *Console) OnLog(fn LogFunc) (remove func()) {
+	return c.onLog(&logHook{fn: fn})
+}
+
+func (c *Console) onLog(h *logHook) (remove func()) {
+	c.hookLock.Lock()
+	defer c.hookLock.Unlock()
+	// The slice is copied on every change so that log can iterate over it without holding the lock
+	hooks := make([]*logHook, len(c.logHooks), len(c.logHooks)+1)
+	copy(hooks, c.logHooks)
+	c.logHooks = append(hooks, h)
+	return func() {
+		c.hookLock.Lock()
+		defer c.hookLock.Unlock()
+		hooks := make([]*logHook, 0, len(c.logHooks))
+		for _, other := range c.logHooks {
+			if other != h {
+				hooks = append(hooks, other)
+			}
+		}
+		c.logHooks = hooks
+	}
+}
+
+// Mirror forwards every message written to the console buffer to the buffer of dst, with the same level.
+// Messages are written with the prefixes and the log level of dst. Messages that arrive through a mirror are
+// never forwarded again, so two consoles can mirror each other. Use Unmirror to stop mirroring.
+func (c *Console) Mirror(dst *Console) {
+	remove := c.onLog(&logHook{fn: dst.forward, mirror: true})
+	c.hookLock.Lock()
+	prev, ok := c.mirrors[dst]
+	c.mirrors[dst] = remove
+	c.hookLock.Unlock()
+	if ok {
+		// Mirroring to the same console twice would duplicate the messages
+		prev()
+	}
+}
+
+// Unmirror stops forwarding messages to dst that is started by Mirror.
+func (c *Console) Unmirror(dst *Console) {
+	c.hookLock.Lock()
+	remove, ok := c.mirrors[dst]
+	delete(c.mirrors, dst)
+	c.hookLock.Unlock()
+	if ok {
+		remove()
+	}
+}
+
+// forward writes a message mirrored from another console like the Log* functions, see Mirror.
+func (c *Console) forward(level LogLevel, prefix, msg string) {
+	if c.discard {
+		return
+	}
+	switch level {
+	case LogInfo:
+		prefix = c.logInfoPrefix
+	case LogWarning:
+		prefix = c.logWarnPrefix
+	case LogError:
+		prefix = c.logErrPrefix
+	default:
+		prefix = ""
+	}
+	// Gated like the Log* functions
+	if level != LogNone && (int32)(LogInfo) > atomic.LoadInt32((*int32)(&c.logLevel)) {
+		return
 	}
-	out := prefix + fmt.Sprintf(format, a...)
-	c.buffer = append(c.buffer, out)
+	c.write(level, prefix, msg, true)
 }
 
 // LogInfof prints an information message to the console.
@@ -41,7 +151,7 @@ func (c *Console) LogInfof(format string, a ...interface{}) {
 	if (int32)(LogInfo) > atomic.LoadInt32((*int32)(&c.logLevel)) {
 		return
 	}
-	c.log(c.logInfoPrefix, format, a...)
+	c.log(LogInfo, c.logInfoPrefix, format, a...)
 }
 
 // LogWarningf prints a warning message to the console.
@@ -49,7 +159,7 @@ func (c *Console) LogWarningf(format string, a ...interface{}) {
 	if (int32)(LogInfo) > atomic.LoadInt32((*int32)(&c.logLevel)) {
 		return
 	}
-	c.log(c.logWarnPrefix, format, a...)
+	c.log(LogWarning, c.logWarnPrefix, format, a...)
 }
 
 // LogErrorf prints an error message to the console.
@@ -57,12 +167,39 @@ func (c *Console) LogErrorf(format string, a ...interface{}) {
 	if (int32)(LogInfo) > atomic.LoadInt32((*int32)(&c.logLevel)) {
 		return
 	}
-	c.log(c.logErrPrefix, format, a...)
+	c.log(LogError, c.logErrPrefix, format, a...)
 }
 
 // LogPrintf prints a message to the console without a prefix, regardless of the log level.
 func (c *Console) LogPrintf(format string, a ...interface{}) {
-	c.log("", format, a...)
+	c.log(LogNone, "", format, a...)
+}
+
+// Write implements io.Writer so that the console can be used as the output of other loggers, for ex. log.SetOutput.
+// Each line is printed to the console like LogPrintf. An incomplete last line is kept until its newline is written.
+// Write never fails.
+func (c *Console) Write(p []byte) (n int, err error) {
+	c.writeLock.Lock()
+	c.partial = append(c.partial, p...)
+	var lines []string
+	for {
+		i := bytes.IndexByte(c.partial, '\n')
+		if i < 0 {
+			break
+		}
+		lines = append(lines, string(bytes.TrimSuffix(c.partial[:i], []byte{'\r'})))
+		c.partial = c.partial[i+1:]
+	}
+	if len(c.partial) == 0 {
+		// Drop the consumed bytes instead of keeping them referenced by an empty slice
+		c.partial = nil
+	}
+	c.writeLock.Unlock()
+
+	for _, line := range lines {
+		c.log(LogNone, "", "%s", line)
+	}
+	return len(p), nil
 }
 
 // SetLogLevel changes the log level that will be written to the console buffer.
@@ -70,24 +207,61 @@ func (c *Console) SetLogLevel(level LogLevel) {
 	atomic.StoreInt32((*int32)(&c.logLevel), (int32)(level))
 }
 
+// lines returns a copy of the buffer lines in chronological order. bufLock must be held, at least for reading, by the caller.
+func (c *Console) lines() []string {
+	ret := make([]string, 0, len(c.buffer))
+	ret = append(ret, c.buffer[c.bufHead:]...)
+	return append(ret, c.buffer[:c.bufHead]...)
+}
+
 // Buffer returns the console buffer as a string.
 func (c *Console) Buffer() string {
-	c.bufLock.Lock()
-	defer c.bufLock.Unlock()
-	return strings.Join(c.buffer, "\n")
+	c.bufLock.RLock()
+	defer c.bufLock.RUnlock()
+	return strings.Join(c.lines(), "\n")
+}
+
+// BufferFiltered returns the lines of the console buffer whose level is at least min, in chronological order.
+// The lines printed via LogPrintf or Write have the level LogNone, so they are only returned when min is LogNone.
+func (c *Console) BufferFiltered(min LogLevel) []string {
+	c.bufLock.RLock()
+	defer c.bufLock.RUnlock()
+	var ret []string
+	n := len(c.buffer)
+	for i := 0; i < n; i++ {
+		j := (c.bufHead + i) % n
+		if c.bufLevels[j] >= min {
+			ret = append(ret, c.buffer[j])
+		}
+	}
+	return ret
 }
 
 // BufferRaw returns the copy of the underlying raw buffer slice. Each element represents a line.
 func (c *Console) BufferRaw() []string {
-	c.bufLock.Lock()
-	defer c.bufLock.Unlock()
-	ret := make([]string, len(c.buffer))
-	copy(ret, c.buffer)
-	return ret
+	c.bufLock.RLock()
+	defer c.bufLock.RUnlock()
+	return c.lines()
+}
+
+// BufferLen returns the number of lines in the console buffer without copying it.
+func (c *Console) BufferLen() int {
+	c.bufLock.RLock()
+	defer c.bufLock.RUnlock()
+	return len(c.buffer)
+}
+
+// BufferCap returns the maximum number of lines in the console buffer, as given to NewConsole.
+// A value of 0 or less means the buffer is unbounded.
+func (c *Console) BufferCap() int {
+	c.bufLock.RLock()
+	defer c.bufLock.RUnlock()
+	return c.bufMaxLines
 }
 
 // BufferWrapped returns the console buffer with each line wrapped to a new line.
 // maxWidth is the maximum number of runes allowed before wrapping it to a new line.
+// If maxWidth is smaller than 1, lines are returned unwrapped.
 //
 // Note that this method doesn't take into account the rare cases of where a single character might
 // be represented by multiple runes (ex: 'é́́'). Use https://pkg.go.dev/golang.org/x/text/unicode/norm together
@@ -98,52 +272,124 @@ func (c *Console) BufferWrapped(maxWidth int) string {
 
 // BufferWrappedRaw returns the console buffer with each line wrapped to a new line as a slice.
 // maxWidth is the maximum number of runes allowed before wrapping it to a new line.
+// If maxWidth is smaller than 1, lines are returned unwrapped.
 func (c *Console) BufferWrappedRaw(maxWidth int) []string {
-	c.bufLock.Lock()
-	defer c.bufLock.Unlock()
 	var ret []string
-	for _, line := range c.buffer {
-		if len([]rune(line)) > maxWidth {
-			ret = append(ret, chunks(line, maxWidth)...)
-		} else {
-			ret = append(ret, line)
+	c.ForEachWrappedLine(maxWidth, func(line string) {
+		ret = append(ret, line)
+	})
+	return ret
+}
+
+// ForEachWrappedLine calls fn for each line of the console buffer wrapped like BufferWrappedRaw, without building
+// a slice of all wrapped lines. Wrapped lines share the memory of the buffer lines, so even a huge line costs nothing
+// extra and a renderer can stop drawing at the visible lines. fn is called without holding any locks.
+func (c *Console) ForEachWrappedLine(maxWidth int, fn func(line string)) {
+	trim := c.trimEnabled()
+	for _, line := range c.BufferRaw() {
+		if trim {
+			line = strings.TrimRightFunc(line, unicode.IsSpace)
 		}
+		wrap(line, maxWidth, fn)
 	}
-	return ret
 }
 
-// ClearBuffer clears the console buffer.
+// wrap calls fn for each chunk of at most maxWidth runes of line, or once for the whole line if maxWidth is smaller than 1.
+func wrap(line string, maxWidth int, fn func(chunk string)) {
+	if maxWidth < 1 || len(line) <= maxWidth {
+		fn(line)
+		return
+	}
+	start, n := 0, 0
+	for i := range line {
+		if n == maxWidth {
+			fn(line[start:i])
+			start, n = i, 0
+		}
+		n++
+	}
+	fn(line[start:])
+}
+
+// ClearBuffer clears the console buffer and then calls the hooks registered via OnClear.
 func (c *Console) ClearBuffer() {
 	c.bufLock.Lock()
-	defer c.bufLock.Unlock()
+	// The lines are released so that they can be garbage collected, the backing array is kept for reuse
+	for i := range c.buffer {
+		c.buffer[i] = ""
+	}
 	c.buffer = c.buffer[:0]
+	c.bufLevels = c.bufLevels[:0]
+	c.bufHead = 0
+	c.bufLock.Unlock()
+
+	c.hookLock.RLock()
+	hooks := c.clearHooks
+	c.hookLock.RUnlock()
+	for _, h := range hooks {
+		h.fn()
+	}
+}
+
+// OnClear registers a hook that is called every time the console buffer is cleared, for ex. by the con_clear
+// command, so that a UI can reset its cached state. Multiple hooks can be registered. Calling the returned function
+// removes the hook.
+func (c *Console) OnClear(fn func()) (remove func()) {
+	h := &clearHook{fn: fn}
+	c.hookLock.Lock()
+	defer c.hookLock.Unlock()
+	hooks := make([]*clearHook, len(c.clearHooks), len(c.clearHooks)+1)
+	copy(hooks, c.clearHooks)
+	c.clearHooks = append(hooks, h)
+	return func() {
+		c.hookLock.Lock()
+		defer c.hookLock.Unlock()
+		hooks := make([]*clearHook, 0, len(c.clearHooks))
+		for _, other := range c.clearHooks {
+			if other != h {
+				hooks = append(hooks, other)
+			}
+		}
+		c.clearHooks = hooks
+	}
 }
 
 // DumpBuffer saves the console buffer to the given file.
 func (c *Console) DumpBuffer(filePath string) error {
-	c.bufLock.Lock()
-	defer c.bufLock.Unlock()
-	return ioutil.WriteFile(filePath, []byte(strings.Join(c.buffer, "\n")), os.ModePerm)
-}
-
-// Thanks to https://stackoverflow.com/questions/25686109/split-string-by-length-in-golang
-func chunks(s string, chunkSize int) []string {
-	if chunkSize >= len(s) {
-		return []string{s}
-	}
-	var chunks []string
-	chunk := make([]rune, chunkSize)
-	len := 0
-	for _, r := range s {
-		chunk[len] = r
-		len++
-		if len == chunkSize {
-			chunks = append(chunks, string(chunk))
-			len = 0
+	// The lines are copied under a brief lock so that logging isn't blocked while the file is written
+	c.bufLock.RLock()
+	lines := c.lines()
+	c.bufLock.RUnlock()
+	return c.dump(filePath, lines)
+}
+
+// DumpBufferFiltered saves the lines of the console buffer whose level is at least min to the given file,
+// for ex. only the warnings and errors for a bug report. See BufferFiltered.
+func (c *Console) DumpBufferFiltered(filePath string, min LogLevel) error {
+	return c.dump(filePath, c.BufferFiltered(min))
+}
+
+// dump writes the given buffer lines to the given file. The lines may be modified.
+func (c *Console) dump(filePath string, lines []string) error {
+	if c.trimEnabled() {
+		for i, line := range lines {
+			lines[i] = strings.TrimRightFunc(line, unicode.IsSpace)
 		}
 	}
-	if len > 0 {
-		chunks = append(chunks, string(chunk[:len]))
+	return c.files().WriteFile(filePath, []byte(strings.Join(lines, "\n")), c.perm())
+}
+
+// SetTrimTrailingSpace enables or disables trimming the trailing whitespace of the lines returned by
+// BufferWrapped, BufferWrappedRaw and saved by DumpBuffer, which is disabled by default.
+// The buffer itself is left untouched, so Buffer and BufferRaw always return the lines as they are logged.
+func (c *Console) SetTrimTrailingSpace(enable bool) {
+	if enable {
+		atomic.StoreInt32(&c.trimSpace, 1)
+	} else {
+		atomic.StoreInt32(&c.trimSpace, 0)
 	}
-	return chunks
+}
+
+func (c *Console) trimEnabled() bool {
+	return atomic.LoadInt32(&c.trimSpace) == 1
 }
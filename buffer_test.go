@@ -0,0 +1,260 @@
+// Copyright © 2020 Cosku Bas. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package convar
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMirrorCycle(t *testing.T) {
+	a := NewConsole(10, LogError, "a: ", "", "")
+	b := NewConsole(10, LogError, "b: ", "", "")
+	a.Mirror(b)
+	b.Mirror(a)
+	a.LogInfof("hi")
+	b.LogInfof("there")
+
+	want := []string{"a: hi", "a: there"}
+	if got := a.BufferRaw(); !reflect.DeepEqual(got, want) {
+		t.Errorf("a buffer = %q, want %q", got, want)
+	}
+	want = []string{"b: hi", "b: there"}
+	if got := b.BufferRaw(); !reflect.DeepEqual(got, want) {
+		t.Errorf("b buffer = %q, want %q", got, want)
+	}
+}
+
+func TestBufferWrappedNonPositiveWidth(t *testing.T) {
+	c := NewConsole(10, LogError, "", "", "")
+	c.LogPrintf("hello world")
+	c.LogPrintf("ünïcödé")
+	for _, width := range []int{0, -1} {
+		if got, want := c.BufferWrappedRaw(width), c.BufferRaw(); !reflect.DeepEqual(got, want) {
+			t.Errorf("BufferWrappedRaw(%d) = %q, want %q", width, got, want)
+		}
+		if got, want := c.BufferWrapped(width), c.Buffer(); got != want {
+			t.Errorf("BufferWrapped(%d) = %q, want %q", width, got, want)
+		}
+	}
+	want := []string{"hello", " worl", "d", "ünïcö", "dé"}
+	if got := c.BufferWrappedRaw(5); !reflect.DeepEqual(got, want) {
+		t.Errorf("BufferWrappedRaw(5) = %q, want %q", got, want)
+	}
+}
+
+func TestTrimTrailingSpace(t *testing.T) {
+	c := NewConsole(10, LogError, "", "", "")
+	storage := &memStorage{files: make(map[string][]byte), hook: func() {}}
+	c.SetStorage(storage)
+	c.LogPrintf("hello   ")
+	c.LogPrintf("wörld\t \t")
+	c.LogPrintf("   ")
+
+	c.SetTrimTrailingSpace(true)
+	want := []string{"hel", "lo", "wör", "ld", ""}
+	if got := c.BufferWrappedRaw(3); !reflect.DeepEqual(got, want) {
+		t.Errorf("BufferWrappedRaw(3) = %q, want %q", got, want)
+	}
+	if got, want := c.BufferWrapped(0), "hello\nwörld\n"; got != want {
+		t.Errorf("BufferWrapped(0) = %q, want %q", got, want)
+	}
+	if err := c.DumpBuffer("dump.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(storage.files["dump.txt"]), "hello\nwörld\n"; got != want {
+		t.Errorf("dump = %q, want %q", got, want)
+	}
+	raw := []string{"hello   ", "wörld\t \t", "   "}
+	if got := c.BufferRaw(); !reflect.DeepEqual(got, raw) {
+		t.Errorf("BufferRaw = %q, want %q", got, raw)
+	}
+
+	c.SetTrimTrailingSpace(false)
+	if err := c.DumpBuffer("dump.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(storage.files["dump.txt"]), c.Buffer(); got != want {
+		t.Errorf("untrimmed dump = %q, want %q", got, want)
+	}
+}
+
+func TestClearBufferReleasesLines(t *testing.T) {
+	c := NewConsole(4, LogError, "", "", "")
+	big := strings.Repeat("x", 1<<20)
+	for i := 0; i < 10; i++ {
+		c.LogPrintf("%s%d", big, i)
+	}
+	if n := cap(c.buffer); n > 4 {
+		t.Errorf("backing array grows to %d lines on overflow, want at most 4", n)
+	}
+	c.ClearBuffer()
+	if n := c.BufferLen(); n != 0 {
+		t.Errorf("BufferLen after ClearBuffer = %d", n)
+	}
+	// The backing array is kept for reuse, but it mustn't keep the old lines alive
+	for i, line := range c.buffer[:cap(c.buffer)] {
+		if line != "" {
+			t.Errorf("slot %d retains a line of %d bytes after ClearBuffer", i, len(line))
+		}
+	}
+	c.LogPrintf("after")
+	if got := c.BufferRaw(); !reflect.DeepEqual(got, []string{"after"}) {
+		t.Errorf("buffer after ClearBuffer = %q, want [after]", got)
+	}
+}
+
+func TestRingBufferOrder(t *testing.T) {
+	c := NewConsole(3, LogError, "", "", "")
+	var want []string
+	for i := 0; i < 8; i++ {
+		c.LogPrintf("line %d", i)
+		want = append(want, fmt.Sprintf("line %d", i))
+		if len(want) > 3 {
+			want = want[1:]
+		}
+		if got := c.BufferRaw(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("after %d lines: BufferRaw = %q, want %q", i+1, got, want)
+		}
+		if got := c.Buffer(); got != strings.Join(want, "\n") {
+			t.Fatalf("after %d lines: Buffer = %q", i+1, got)
+		}
+		if got := c.BufferFiltered(LogNone); !reflect.DeepEqual(got, want) {
+			t.Fatalf("after %d lines: BufferFiltered = %q, want %q", i+1, got, want)
+		}
+	}
+}
+
+func BenchmarkLogAtCapacity(b *testing.B) {
+	c := NewConsole(1000, LogError, "", "", "")
+	for i := 0; i < 1000; i++ {
+		c.LogPrintf("warmup")
+	}
+	const msg = "player connected"
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// The message has no arguments, so the only allocation left is the line itself
+		c.LogPrintf(msg)
+	}
+}
+
+func TestConcurrentLogAndRead(t *testing.T) {
+	const (
+		writers = 4
+		lines   = 500
+	)
+	c := NewConsole(100, LogError, "", "", "")
+	c.SetStorage(discardStorage{})
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < lines; i++ {
+				c.LogPrintf("writer %d line %d", w, i)
+			}
+		}(w)
+	}
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if n := len(c.BufferRaw()); n > 100 {
+				t.Errorf("BufferRaw has %d lines, want at most 100", n)
+			}
+			c.Buffer()
+			c.BufferWrapped(10)
+			if err := c.DumpBuffer("dump.txt"); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	wg.Wait()
+	close(done)
+	readers.Wait()
+
+	if n := c.BufferLen(); n != 100 {
+		t.Errorf("BufferLen = %d, want 100", n)
+	}
+	// Every writer's own lines stay in order
+	last := make(map[int]int)
+	for _, line := range c.BufferRaw() {
+		var w, i int
+		if _, err := fmt.Sscanf(line, "writer %d line %d", &w, &i); err != nil {
+			t.Fatalf("malformed line %q", line)
+		}
+		if prev, ok := last[w]; ok && i <= prev {
+			t.Errorf("writer %d line %d comes after line %d", w, i, prev)
+		}
+		last[w] = i
+	}
+}
+
+// discardStorage is a storage that discards the written files, so it's safe for concurrent use.
+type discardStorage struct{}
+
+func (discardStorage) ReadFile(name string) ([]byte, error) {
+	return nil, os.ErrNotExist
+}
+
+func (discardStorage) WriteFile(name string, data []byte, perm os.FileMode) error {
+	return nil
+}
+
+func TestUnboundedBuffer(t *testing.T) {
+	for _, size := range []int{0, -1, -100} {
+		c := NewConsole(size, LogError, "", "", "")
+		for i := 0; i < 1000; i++ {
+			c.LogPrintf("line %d", i)
+		}
+		if n := c.BufferLen(); n != 1000 {
+			t.Errorf("size %d: BufferLen = %d, want 1000", size, n)
+		}
+		if n := c.BufferCap(); n != size {
+			t.Errorf("size %d: BufferCap = %d", size, n)
+		}
+		lines := c.BufferRaw()
+		if lines[0] != "line 0" || lines[999] != "line 999" {
+			t.Errorf("size %d: buffer is not in order: %q ... %q", size, lines[0], lines[999])
+		}
+		c.ClearBuffer()
+		c.LogPrintf("after")
+		if got := c.BufferRaw(); !reflect.DeepEqual(got, []string{"after"}) {
+			t.Errorf("size %d: buffer after ClearBuffer = %q", size, got)
+		}
+	}
+}
+
+func TestLogLevels(t *testing.T) {
+	for level, want := range map[LogLevel][]string{
+		LogNone: {"print"},
+		// Warnings and errors are written whenever information messages are
+		LogInfo:    {"I: info", "W: warning", "E: error", "print"},
+		LogWarning: {"I: info", "W: warning", "E: error", "print"},
+		LogError:   {"I: info", "W: warning", "E: error", "print"},
+	} {
+		c := NewConsole(10, level, "I: ", "W: ", "E: ")
+		c.LogInfof("info")
+		c.LogWarningf("warning")
+		c.LogErrorf("error")
+		c.LogPrintf("print")
+		if got := c.BufferRaw(); !reflect.DeepEqual(got, want) {
+			t.Errorf("level %d: buffer = %q, want %q", level, got, want)
+		}
+	}
+}
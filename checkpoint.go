@@ -0,0 +1,90 @@
+// Copyright © 2020 Cosku Bas. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package convar
+
+import (
+	"context"
+	"fmt"
+)
+
+// checkpoint is a snapshot of the convar values taken by Checkpoint.
+type checkpoint struct {
+	id     int
+	values map[*ConVar]interface{}
+}
+
+// Checkpoint takes a snapshot of the values of all convars and returns its id, for ex. when a settings menu is opened.
+// Checkpoints form a stack, so they can be nested. Use Rollback to restore the values of a checkpoint
+// or Commit to discard it once the changes are accepted.
+func (c *Console) Checkpoint() (id int) {
+	values := make(map[*ConVar]interface{})
+	c.varLock.RLock()
+	c.valLock.Lock()
+	for _, cv := range c.variables {
+		if !cv.isFunc && cv.getter == nil {
+			values[cv] = cv.value.Load()
+		}
+	}
+	c.valLock.Unlock()
+	c.varLock.RUnlock()
+
+	c.cpLock.Lock()
+	defer c.cpLock.Unlock()
+	c.cpNext++
+	c.checkpoints = append(c.checkpoints, checkpoint{id: c.cpNext, values: values})
+	return c.cpNext
+}
+
+// Rollback restores the values of the convars to the ones of the given checkpoint, triggering the set/update
+// callbacks of the convars whose value is changed. The checkpoint and all checkpoints taken after it are discarded.
+// Convars registered after the checkpoint are not affected.
+func (c *Console) Rollback(id int) error {
+	cp, err := c.popCheckpoint(id)
+	if err != nil {
+		return err
+	}
+	type restore struct {
+		cv     *ConVar
+		oldVal interface{}
+		newVal interface{}
+	}
+	var restores []restore
+	c.valLock.Lock()
+	for cv, value := range cp.values {
+		cv.setLock.Lock()
+		if oldVal := cv.value.Load(); oldVal != value {
+			cv.value.Store(value)
+			c.recordChange(cv)
+			restores = append(restores, restore{cv: cv, oldVal: oldVal, newVal: value})
+		}
+		cv.setLock.Unlock()
+	}
+	c.valLock.Unlock()
+
+	// Callbacks are triggered once all values are restored, without holding any locks
+	for _, r := range restores {
+		r.cv.changed(context.Background(), r.oldVal, r.newVal, false)
+	}
+	return nil
+}
+
+// Commit discards the given checkpoint and all checkpoints taken after it, keeping the current values.
+func (c *Console) Commit(id int) error {
+	_, err := c.popCheckpoint(id)
+	return err
+}
+
+// popCheckpoint removes the checkpoint with the given id and the ones above it from the stack.
+func (c *Console) popCheckpoint(id int) (checkpoint, error) {
+	c.cpLock.Lock()
+	defer c.cpLock.Unlock()
+	for i, cp := range c.checkpoints {
+		if cp.id == id {
+			c.checkpoints = c.checkpoints[:i]
+			return cp, nil
+		}
+	}
+	return checkpoint{}, fmt.Errorf(c.str().ErrUnknownCheckpoint, id)
+}
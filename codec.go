@@ -0,0 +1,117 @@
+// Copyright © 2020 Cosku Bas. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package convar
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ParseFunc is the function signature of a value parser.
+// It converts the string form of a value, as typed in a command or a config file, to a convar value.
+type ParseFunc func(s string) (interface{}, error)
+
+// FormatFunc is the function signature of a value formatter.
+// It converts a convar value to the string form that is written to config files.
+// The result must be parseable back by the parser of the same type.
+type FormatFunc func(value interface{}) string
+
+var defaultParsers = map[reflect.Kind]ParseFunc{
+	reflect.Int: parseInt,
+	reflect.Float64: func(s string) (interface{}, error) {
+		return strconv.ParseFloat(s, 64)
+	},
+	reflect.String: func(s string) (interface{}, error) {
+		return s, nil
+	},
+}
+
+// parseInt parses decimal ints, as well as hexadecimal, octal and binary ones with the 0x, 0o and 0b prefixes,
+// for ex. 0x1F for a bitmask. A leading zero alone doesn't mean octal, so 010 is still 10.
+func parseInt(s string) (interface{}, error) {
+	digits := strings.TrimLeft(s, "+-")
+	base := 10
+	if len(digits) > 1 && digits[0] == '0' && strings.ContainsRune("xXoObB", rune(digits[1])) {
+		// Base 0 detects the base from the prefix
+		base = 0
+	}
+	i, err := strconv.ParseInt(s, base, 0)
+	return int(i), err
+}
+
+// SetParser sets the parser that is used to convert command values of the given type.
+// The parser must return a value of the given type. Passing a nil parser restores the default one.
+func (c *Console) SetParser(kind reflect.Kind, fn ParseFunc) {
+	c.codecLock.Lock()
+	defer c.codecLock.Unlock()
+	if fn == nil {
+		delete(c.parsers, kind)
+		return
+	}
+	c.parsers[kind] = fn
+}
+
+func (c *Console) parser(kind reflect.Kind) ParseFunc {
+	c.codecLock.RLock()
+	defer c.codecLock.RUnlock()
+	if fn, ok := c.parsers[kind]; ok {
+		return fn
+	}
+	return defaultParsers[kind]
+}
+
+var defaultFormatters = map[reflect.Kind]FormatFunc{
+	reflect.Int: func(value interface{}) string {
+		return strconv.Itoa(value.(int))
+	},
+	reflect.Float64: func(value interface{}) string {
+		// Shortest representation that parses back to the identical float64
+		return strconv.FormatFloat(value.(float64), 'g', -1, 64)
+	},
+	reflect.String: func(value interface{}) string {
+		// Quoted only when needed to parse back to the same string
+		s := value.(string)
+		if s == "" || strings.HasPrefix(s, "\"") || strings.HasPrefix(s, `\$`) || strings.TrimSpace(s) != s {
+			return quote(s)
+		}
+		if strings.HasPrefix(s, "$") {
+			// Escaped so that it isn't loaded as a reference to another convar
+			return `\` + s
+		}
+		return s
+	},
+}
+
+// SetFormatter sets the formatter that is used by Save to convert values of the given type to strings.
+// Passing a nil formatter restores the default one.
+func (c *Console) SetFormatter(kind reflect.Kind, fn FormatFunc) {
+	c.codecLock.Lock()
+	defer c.codecLock.Unlock()
+	if fn == nil {
+		delete(c.formatters, kind)
+		return
+	}
+	c.formatters[kind] = fn
+}
+
+// formatter returns the formatter for the given kind. It is safe to call on a nil console.
+func (c *Console) formatter(kind reflect.Kind) FormatFunc {
+	if c != nil {
+		c.codecLock.RLock()
+		fn, ok := c.formatters[kind]
+		c.codecLock.RUnlock()
+		if ok {
+			return fn
+		}
+	}
+	if fn, ok := defaultFormatters[kind]; ok {
+		return fn
+	}
+	return func(value interface{}) string {
+		return fmt.Sprint(value)
+	}
+}
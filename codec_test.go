@@ -0,0 +1,56 @@
+// Copyright © 2020 Cosku Bas. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package convar
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIntBases(t *testing.T) {
+	c := NewConsole(10, LogError, "", "", "")
+	cv := NewConVar("r_flags", reflect.Int, false, "", 0, nil)
+	c.RegConVar(cv)
+
+	for value, want := range map[string]int{
+		"31":     31,
+		"-31":    -31,
+		"+31":    31,
+		"010":    10,
+		"0x1F":   31,
+		"0X1f":   31,
+		"-0x1F":  -31,
+		"0o17":   15,
+		"0b1011": 11,
+		"0":      0,
+		"0x_1F":  31,
+	} {
+		if _, err := c.ExecCmd("r_flags " + value); err != nil {
+			t.Errorf("r_flags %s: %v", value, err)
+			continue
+		}
+		if got := cv.MustInt(); got != want {
+			t.Errorf("r_flags %s = %d, want %d", value, got, want)
+		}
+	}
+
+	for value, want := range map[string]string{
+		"0x":                   "can't convert",
+		"0b102":                "can't convert",
+		"1_000":                "can't convert",
+		"0x1FFFFFFFFFFFFFFFFF": "out of the range",
+		"-0x1FFFFFFFFFFFFFFFF": "out of the range",
+	} {
+		cv.SetInt(7)
+		_, err := c.ExecCmd("r_flags " + value)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("r_flags %s: error = %v, want one containing %q", value, err, want)
+		}
+		if got := cv.MustInt(); got != 7 {
+			t.Errorf("r_flags %s changes the value to %d", value, got)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright © 2020 Cosku Bas. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package convar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// DefaultIfFunc is the callback of the if command: if <convar> <op> <value> then <command>
+// The current value of the convar is compared to the given value, and the command is executed if the comparison holds.
+// Supported operators are ==, != for all types, and <, > for int and float64 convars.
+// Unlike other func convars, the if command can be used in config files, where the command is then executed
+// with the same rules as the rest of the file.
+func DefaultIfFunc(ctx context.Context, con *Console, oldVal, newVal interface{}) {
+	if err := con.evalIf(ctx, newVal.(string)); err != nil {
+		con.LogErrorf("%v", err)
+	}
+}
+
+func (c *Console) evalIf(ctx context.Context, cond string) error {
+	var (
+		parts [4]string
+		rest  = cond
+		err   error
+	)
+	for i := range parts {
+		if parts[i], rest, err = nextToken(rest); err != nil {
+			return c.tokenErr(err)
+		}
+	}
+	varName, op, valStr, then := parts[0], parts[1], parts[2], parts[3]
+	if then != "then" || rest == "" {
+		return errors.New(c.str().ErrBadCondition)
+	}
+
+	cv := c.ConVar(varName)
+	if cv == nil {
+		return fmt.Errorf(c.str().ErrVarNotFound, varName)
+	}
+	if cv.isWriteOnly() {
+		return fmt.Errorf(c.str().ErrWriteOnly, cv.varName)
+	}
+	parse := c.parser(cv.varType)
+	if parse == nil {
+		return fmt.Errorf(c.str().ErrUnsupportedType, cv.varType)
+	}
+	value, err := parse(valStr)
+	if err != nil || reflect.TypeOf(value).Kind() != cv.varType {
+		return fmt.Errorf(c.str().ErrBadStringConversion, valStr, cv.varType)
+	}
+
+	var ok bool
+	current := cv.load()
+	switch op {
+	case "==":
+		ok = current == value
+	case "!=":
+		ok = current != value
+	case "<", ">":
+		if cv.varType != reflect.Int && cv.varType != reflect.Float64 {
+			return fmt.Errorf(c.str().ErrBadOperator, op, cv.varType)
+		}
+		if op == "<" {
+			ok = compare(current, value) < 0
+		} else {
+			ok = compare(current, value) > 0
+		}
+	default:
+		return fmt.Errorf(c.str().ErrBadOperator, op, cv.varType)
+	}
+	if !ok {
+		return nil
+	}
+
+	if err := c.enter(); err != nil {
+		return err
+	}
+	defer c.leave()
+	_, _, err = c.exec(ctx, stateFrom(ctx).fromFile, rest)
+	return err
+}
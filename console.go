@@ -5,41 +5,366 @@
 package convar
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
 )
 
 // Console is a Quake-like console implementation for games.
+// It's safe for concurrent use. No lock of the console or its convars is held while user code given to the console
+// runs, which includes set/update callbacks, the unknown command handler and log hooks, so they can freely
+// register, unregister, set or reset convars. The only exception is the function given to ForEachConVar.
 type Console struct {
 	variables     map[string]*ConVar
+	unknown       UnknownFunc
+	deprecated    map[string]string
 	varLock       sync.RWMutex
+	valLock       sync.RWMutex // Held shared by value stores and exclusively by group reads
 	buffer        []string
-	bufLock       sync.Mutex
+	bufLevels     []LogLevel // Level of each line in buffer, at the same index
+	bufLock       sync.RWMutex
 	bufMaxLines   int
+	bufHead       int    // Index of the oldest line once the buffer is full
+	partial       []byte // Incomplete last line given to Write
+	writeLock     sync.Mutex
+	logHooks      []*logHook
+	clearHooks    []*clearHook
+	mirrors       map[*Console]func()
+	hookLock      sync.RWMutex
 	logLevel      LogLevel
 	logInfoPrefix string
 	logWarnPrefix string
 	logErrPrefix  string
+	strs          atomic.Value
+	configSep     atomic.Value
+	storage       atomic.Value
+	parsers       map[reflect.Kind]ParseFunc
+	formatters    map[reflect.Kind]FormatFunc
+	codecLock     sync.RWMutex
+	echo          int32
+	echoCmd       int32
+	depth         int32
+	maxDepth      int32
+	maxCmdLen     int32
+	stats         int32
+	expandEnv     int32
+	suggestMinLen int32
+	trimSpace     int32
+	disabled      int32
+	caseSensitive int32
+	fileMode      uint32
+	prefixExec    int32
+	regSeq        int32 // Guarded by varLock
+	discard       bool  // Set by NewNullConsole and never changed
+	binds         map[string]string
+	bindLock      sync.RWMutex
+	checkpoints   []checkpoint
+	cpNext        int
+	cpLock        sync.Mutex
+	dirty         int32
+	framing       int32
+	frameSeen     map[*ConVar]bool
+	frameCvs      []*ConVar
+	frameLock     sync.Mutex
+	suppress      int32
+	suppressOld   map[*ConVar]interface{}
+	suppressCvs   []*ConVar
+	suppressForce map[*ConVar]bool
+	suppressLock  sync.Mutex
 }
 
 // NewConsole creates a new console instance with the given settings.
-// If buffer size reaches bufMaxLines, old lines will be discarded.
+// If buffer size reaches bufMaxLines, old lines will be discarded. A bufMaxLines of 0 or less means the buffer
+// is unbounded and keeps every line until it's cleared.
 // Only logs that are of smaller level than logLevel will be written to the buffer.
 func NewConsole(bufMaxLines int, logLevel LogLevel, logInfoPrefix string, logWarnPrefix string, logErrPrefix string) *Console {
 	c := &Console{
 		variables:     make(map[string]*ConVar),
+		deprecated:    make(map[string]string),
+		parsers:       make(map[reflect.Kind]ParseFunc),
+		formatters:    make(map[reflect.Kind]FormatFunc),
+		binds:         make(map[string]string),
+		mirrors:       make(map[*Console]func()),
 		bufMaxLines:   bufMaxLines,
 		logLevel:      logLevel,
 		logInfoPrefix: logInfoPrefix,
 		logWarnPrefix: logWarnPrefix,
 		logErrPrefix:  logErrPrefix,
+		maxDepth:      defaultMaxDepth,
+		suggestMinLen: defaultSuggestMinLen,
 	}
+	c.SetStrings(defaultStrings)
 	return c
 }
 
+// NewConsoleSized is like NewConsole but preallocates room for the given number of convars, which avoids growing
+// the registry repeatedly when hundreds of convars are registered at startup.
+func NewConsoleSized(bufMaxLines int, expectedConVars int, logLevel LogLevel, logInfoPrefix string, logWarnPrefix string, logErrPrefix string) *Console {
+	c := NewConsole(bufMaxLines, logLevel, logInfoPrefix, logWarnPrefix, logErrPrefix)
+	if expectedConVars > 0 {
+		c.variables = make(map[string]*ConVar, expectedConVars)
+	}
+	return c
+}
+
+// NewNullConsole creates a console that discards everything logged to it, analogous to io.Discard.
+// Its level is LogNone and its buffer always stays empty, log hooks aren't called either.
+// Registering and executing convars works as usual, which makes it handy for tests that don't care about the output.
+func NewNullConsole() *Console {
+	c := NewConsole(0, LogNone, "", "", "")
+	c.discard = true
+	return c
+}
+
+// EnableStats enables or disables collecting read and write counts of the convars, which is disabled by default.
+// There is no counting overhead while stats are disabled. See ConVar.Stats.
+func (c *Console) EnableStats(enable bool) {
+	if enable {
+		atomic.StoreInt32(&c.stats, 1)
+	} else {
+		atomic.StoreInt32(&c.stats, 0)
+	}
+}
+
+// statsEnabled reports whether stats are enabled. It is safe to call on a nil console.
+func (c *Console) statsEnabled() bool {
+	return c != nil && atomic.LoadInt32(&c.stats) == 1
+}
+
+// Stats returns the total read and write counts of all registered convars. See ConVar.Stats.
+func (c *Console) Stats() (reads, writes uint64) {
+	c.varLock.RLock()
+	defer c.varLock.RUnlock()
+	for _, cv := range c.variables {
+		r, w := cv.Stats()
+		reads += r
+		writes += w
+	}
+	return reads, writes
+}
+
+// SetCaseSensitive enables or disables the case-sensitive mode, which is disabled by default.
+// By default convar names are case insensitive and executed commands are lowercased as a whole, values included.
+// In case-sensitive mode, names keep the case given to NewConVar and neither names nor values are lowercased.
+// It must be set before any convars are registered, since it decides the names they are registered with.
+func (c *Console) SetCaseSensitive(enable bool) {
+	if enable {
+		atomic.StoreInt32(&c.caseSensitive, 1)
+	} else {
+		atomic.StoreInt32(&c.caseSensitive, 0)
+	}
+}
+
+// fold returns s as it is in case-sensitive mode and lowercased otherwise. It's applied to every name and command.
+func (c *Console) fold(s string) string {
+	if atomic.LoadInt32(&c.caseSensitive) == 1 {
+		return s
+	}
+	return strings.ToLower(s)
+}
+
+// SetPrefixExec enables or disables executing commands by an unambiguous prefix of their name,
+// for ex. cl_wid for cl_width, which is disabled by default. An exact match always wins, and a prefix that matches
+// more than one convar results in an error listing them. Config files always require exact names.
+// Hidden convars, unless the showhidden convar is set, and the ones above the privilege level given to ExecCmdPriv
+// are left out of the prefix matching.
+func (c *Console) SetPrefixExec(enable bool) {
+	if enable {
+		atomic.StoreInt32(&c.prefixExec, 1)
+	} else {
+		atomic.StoreInt32(&c.prefixExec, 0)
+	}
+}
+
+// ErrDisabled is returned for the commands executed while the console is disabled. See SetEnabled.
+var ErrDisabled = errors.New("console is disabled")
+
+// SetEnabled enables or disables the execution of commands, for ex. to ignore the console input during a cutscene.
+// While disabled, ExecCmd and the other Exec* methods, including the commands run by ExecBind, return ErrDisabled
+// without doing anything.
+// Load, the typed setters and logging keep working. The console is enabled by default.
+func (c *Console) SetEnabled(enable bool) {
+	if enable {
+		atomic.StoreInt32(&c.disabled, 0)
+	} else {
+		atomic.StoreInt32(&c.disabled, 1)
+	}
+}
+
+// IsEnabled reports whether the console executes commands. See SetEnabled.
+func (c *Console) IsEnabled() bool {
+	return atomic.LoadInt32(&c.disabled) == 0
+}
+
+// SetExpandEnv enables or disables the expansion of environment variables in the values of string convars given
+// to executed commands, which is disabled by default so that untrusted config files can't read the environment.
+// When enabled, ${NAME} and $NAME are replaced as in os.ExpandEnv, for ex. `con_dump ${HOME}/game.log`.
+// Names are matched case insensitively. A value of $name that refers to an existing convar is still replaced with
+// the value of the convar instead, and a value starting with \$ is taken literally without any expansion.
+func (c *Console) SetExpandEnv(enable bool) {
+	if enable {
+		atomic.StoreInt32(&c.expandEnv, 1)
+	} else {
+		atomic.StoreInt32(&c.expandEnv, 0)
+	}
+}
+
+// SetMaxCmdLen sets the maximum number of runes of a command executed via ExecCmd and its variants.
+// Longer commands are rejected with an error before being parsed. Zero, the default, means no limit.
+// Regardless of this setting, control characters other than whitespace are always stripped from such commands.
+// This hardens the console when commands come from untrusted sources like a network. Config files are not affected.
+func (c *Console) SetMaxCmdLen(n int) {
+	atomic.StoreInt32(&c.maxCmdLen, int32(n))
+}
+
+// defaultMaxDepth is the default maximum depth of nested command executions.
+const defaultMaxDepth = 16
+
+// SetMaxDepth sets the maximum depth of nested command executions, for ex. a config file loading another one.
+// Exceeding it results in an error, which protects against cyclic loading. The default is 16.
+// Note that the depth is counted per console, regardless of the goroutine doing the execution.
+func (c *Console) SetMaxDepth(n int) {
+	atomic.StoreInt32(&c.maxDepth, int32(n))
+}
+
+// enter increases the nesting depth of the console.
+// Every successful call must be followed by a call to leave.
+func (c *Console) enter() error {
+	if depth := atomic.AddInt32(&c.depth, 1); depth > atomic.LoadInt32(&c.maxDepth) {
+		atomic.AddInt32(&c.depth, -1)
+		return fmt.Errorf(c.str().ErrMaxDepth, depth-1)
+	}
+	return nil
+}
+
+func (c *Console) leave() {
+	atomic.AddInt32(&c.depth, -1)
+}
+
+// SetEcho enables or disables the echo mode, which is disabled by default.
+// In echo mode, executed commands log their result to the console buffer:
+// "name value" after setting a convar, the value after querying a convar and nothing after running a func convar.
+func (c *Console) SetEcho(echo bool) {
+	if echo {
+		atomic.StoreInt32(&c.echo, 1)
+	} else {
+		atomic.StoreInt32(&c.echo, 0)
+	}
+}
+
+// SetEchoAsCommand enables or disables printing queried convars as commands, which is disabled by default.
+// When enabled, querying a convar prints "name value" in the form returned by ConVar.AsCommand instead of just
+// the value, both in echo mode and in the output returned by ExecCmdOutput, so that it can be copied into a config.
+func (c *Console) SetEchoAsCommand(enable bool) {
+	if enable {
+		atomic.StoreInt32(&c.echoCmd, 1)
+	} else {
+		atomic.StoreInt32(&c.echoCmd, 0)
+	}
+}
+
+// queryLine returns the line printed after querying the given convar.
+func (c *Console) queryLine(cv *ConVar) string {
+	if atomic.LoadInt32(&c.echoCmd) == 1 {
+		return cv.AsCommand()
+	}
+	return cv.DisplayValue()
+}
+
+// Clone returns an independent console with the same settings, convars and binds but an empty buffer.
+// Each convar is copied with its current value, which can then be changed without affecting the original console.
+// Callbacks are shared by reference, so the ones that capture external state (like the Bind* helpers) keep
+// writing to the same place.
+func (c *Console) Clone() *Console {
+	cp := NewConsole(c.bufMaxLines, LogLevel(atomic.LoadInt32((*int32)(&c.logLevel))), c.logInfoPrefix, c.logWarnPrefix, c.logErrPrefix)
+	cp.discard = c.discard
+	cp.SetStrings(*c.str())
+	cp.SetConfigSeparator(c.separator())
+	cp.SetStorage(c.files())
+	atomic.StoreUint32(&cp.fileMode, atomic.LoadUint32(&c.fileMode))
+	atomic.StoreInt32(&cp.prefixExec, atomic.LoadInt32(&c.prefixExec))
+	atomic.StoreInt32(&cp.echo, atomic.LoadInt32(&c.echo))
+	atomic.StoreInt32(&cp.echoCmd, atomic.LoadInt32(&c.echoCmd))
+	atomic.StoreInt32(&cp.maxDepth, atomic.LoadInt32(&c.maxDepth))
+	atomic.StoreInt32(&cp.maxCmdLen, atomic.LoadInt32(&c.maxCmdLen))
+	atomic.StoreInt32(&cp.stats, atomic.LoadInt32(&c.stats))
+	atomic.StoreInt32(&cp.expandEnv, atomic.LoadInt32(&c.expandEnv))
+	atomic.StoreInt32(&cp.suggestMinLen, atomic.LoadInt32(&c.suggestMinLen))
+	atomic.StoreInt32(&cp.trimSpace, atomic.LoadInt32(&c.trimSpace))
+	atomic.StoreInt32(&cp.disabled, atomic.LoadInt32(&c.disabled))
+	atomic.StoreInt32(&cp.caseSensitive, atomic.LoadInt32(&c.caseSensitive))
+	atomic.StoreInt32(&cp.dirty, atomic.LoadInt32(&c.dirty))
+
+	c.codecLock.RLock()
+	for kind, fn := range c.parsers {
+		cp.parsers[kind] = fn
+	}
+	for kind, fn := range c.formatters {
+		cp.formatters[kind] = fn
+	}
+	c.codecLock.RUnlock()
+
+	c.bindLock.RLock()
+	for key, cmd := range c.binds {
+		cp.binds[key] = cmd
+	}
+	c.bindLock.RUnlock()
+
+	c.varLock.RLock()
+	cp.unknown = c.unknown
+	for oldName, newName := range c.deprecated {
+		cp.deprecated[oldName] = newName
+	}
+	for name, cv := range c.variables {
+		cvCopy := cv.clone()
+		cvCopy.console = cp
+		cp.variables[name] = cvCopy
+	}
+	cp.regSeq = c.regSeq
+	c.varLock.RUnlock()
+	return cp
+}
+
+// SetStrings replaces the user-facing messages of the console, for ex. to localize it.
+// Empty fields keep their default message, so a partial translation doesn't need to start from DefaultStrings.
+// Descriptions of the default convars are only affected if SetStrings is called before RegDefaultConVars.
+func (c *Console) SetStrings(s Strings) {
+	v, def := reflect.ValueOf(&s).Elem(), reflect.ValueOf(defaultStrings)
+	for i := 0; i < v.NumField(); i++ {
+		if v.Field(i).String() == "" {
+			v.Field(i).SetString(def.Field(i).String())
+		}
+	}
+	c.strs.Store(&s)
+}
+
+// str returns the active strings of the console. It is safe to call on a nil console.
+func (c *Console) str() *Strings {
+	if c != nil {
+		if s, ok := c.strs.Load().(*Strings); ok {
+			return s
+		}
+	}
+	return &defaultStrings
+}
+
+// tokenErr returns the given tokenizer error with the message of the console, see errQuote.
+func (c *Console) tokenErr(err error) error {
+	if err == errQuote {
+		return errors.New(c.str().ErrUnterminatedQuote)
+	}
+	return err
+}
+
 // RegDefaultConVars registers an assortment of useful convars.
 //		con_dump:		Saves the console buffer to a file.
 //		con_clear:		Clears the console buffer.
@@ -47,80 +372,105 @@ func NewConsole(bufMaxLines int, logLevel LogLevel, logInfoPrefix string, logWar
 //		var_reset_all:	Resets all convars to their default values without triggering their callbacks.
 //		var_load:		Loads convars from a file, overwriting the ones that are already in the memory.
 //		var_save:		Saves convars to a file.
-//		var_list:		Lists all convars with their description.
+//		var_list:		Lists all convars with their description, sorted by name.
+//		help:			Prints the description and the usage of given convar.
+//		bind:			Binds a command to a key, or prints the command bound to a key.
+//		showhidden:		Shows the hidden convars in the lists and suggestions.
+//		addvar:			Adds an amount to the value of given numeric convar.
+//		scalevar:		Multiplies the value of given numeric convar by a factor.
+//		if:				Executes a command if a condition holds: if <convar> <op> <value> then <command>
+// Their callbacks are exported as the Default*Func functions, so that they can be reused by custom convars.
 func (c *Console) RegDefaultConVars() {
-	c.RegConVar(
-		NewConVar("con_dump", reflect.String, true, "Saves the console buffer to a file.", "console.log", func(con *Console, oldVal, newVal interface{}) {
-			file := newVal.(string)
-			if file == "" {
-				file = oldVal.(string)
-			}
-			if err := con.DumpBuffer(file); err != nil {
-				con.LogErrorf("%v", err)
-				return
-			}
-			con.LogInfof("%s is saved", file)
-		}),
-	)
-	c.RegConVar(
-		NewConVar("con_clear", reflect.Int, true, "Clears the console buffer.", 0, func(con *Console, oldVal, newVal interface{}) {
-			con.ClearBuffer()
-		}),
-	)
-	c.RegConVar(
-		NewConVar("var_reset_all", reflect.Int, true, "Resets all convars to their default values.", 0, func(con *Console, oldVal, newVal interface{}) {
-			con.ResetAllVar()
-		}),
-	)
-	c.RegConVar(
-		NewConVar("var_reset", reflect.String, true, "Resets given convar to its default value.", "", func(con *Console, oldVal, newVal interface{}) {
-			if newVal == nil {
-				con.LogErrorf(errNilValue)
-				return
-			}
-			cv := con.ConVar(newVal.(string))
-			if cv == nil {
-				con.LogErrorf(errVarNotFound, newVal.(string))
-				return
-			}
-			cv.Reset()
-			con.LogInfof("%s is reset", newVal.(string))
-		}),
-	)
-	c.RegConVar(
-		NewConVar("var_load", reflect.String, true, "Loads convars from a file, overwriting the ones that are already in the memory.", "convars.ini", func(con *Console, oldVal, newVal interface{}) {
-			file := newVal.(string)
-			if file == "" {
-				file = oldVal.(string)
-			}
-			if err := con.Load(file); err != nil {
-				con.LogErrorf("%v", err)
-				return
-			}
-			con.LogInfof("%s is loaded", file)
-		}),
-	)
-	c.RegConVar(
-		NewConVar("var_save", reflect.String, true, "Saves convars to a file.", "convars.ini", func(con *Console, oldVal, newVal interface{}) {
-			file := newVal.(string)
-			if file == "" {
-				file = oldVal.(string)
-			}
-			if err := con.Save(file); err != nil {
-				con.LogErrorf("%v", err)
-				return
-			}
-			con.LogInfof("%s is saved", file)
-		}),
-	)
-	c.RegConVar(
-		NewConVar("var_list", reflect.Int, true, "Lists all convars with their description.", 0, func(con *Console, oldVal, newVal interface{}) {
-			cvs := con.ConVars()
-			for _, cv := range cvs {
-				con.LogInfof("%s: %s", cv.varName, cv.varDesc)
-			}
-		}),
-	)
+	c.RegDefaultConVarsExcept()
+}
+
+// RegDefaultConVarsExcept is like RegDefaultConVars but leaves out the convars with the given names,
+// for ex. var_save, var_load and con_dump in a build that must not access the file system.
+// It panics if any of the names is not one of DefaultConVarNames, so that a typo can't leave a convar exposed.
+func (c *Console) RegDefaultConVarsExcept(names ...string) {
+	skip := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if !isDefaultConVar(name) {
+			panic(fmt.Errorf(errNotDefaultConVar, name))
+		}
+		skip[name] = true
+	}
+	reg := func(cv *ConVar) {
+		if !skip[cv.varName] {
+			c.RegConVar(cv)
+		}
+	}
+
+	reg(NewConVar("con_dump", reflect.String, true, c.str().DescConDump, "console.log", DefaultDumpFunc))
+	reg(NewConVar("con_clear", reflect.Int, true, c.str().DescConClear, 0, DefaultClearFunc))
+	reg(NewConVarContext("var_reset_all", reflect.Int, true, c.str().DescVarResetAll, 0, DefaultResetAllFunc))
+	reg(NewFuncContext("var_reset", reflect.String, true, c.str().DescVarReset, "", DefaultResetFunc))
+	reg(NewConVarContext("var_load", reflect.String, true, c.str().DescVarLoad, "convars.ini", DefaultLoadFunc))
+	reg(NewConVar("var_save", reflect.String, true, c.str().DescVarSave, "convars.ini", DefaultSaveFunc))
+	reg(NewConVarContext("var_list", reflect.Int, true, c.str().DescVarList, 0, DefaultListFunc))
+	reg(NewConVarContext("help", reflect.String, true, c.str().DescHelp, "help", DefaultHelpFunc))
+	reg(NewConVarContext("bind", reflect.String, true, c.str().DescBind, "", DefaultBindFunc).Greedy())
+	reg(NewConVar("showhidden", reflect.Int, false, c.str().DescShowHidden, 0, nil).AsBool())
+	reg(NewConVarContext("addvar", reflect.String, true, c.str().DescAddVar, "", DefaultAddVarFunc))
+	reg(NewConVarContext("scalevar", reflect.String, true, c.str().DescScaleVar, "", DefaultScaleVarFunc))
+	cond := NewConVarContext("if", reflect.String, true, c.str().DescIf, "", DefaultIfFunc)
+	cond.fileSafe = true
+	reg(cond)
+
+	usages := map[string]string{
+		"con_dump":  "con_dump [file]",
+		"var_reset": "var_reset <convar>",
+		"var_load":  "var_load [file]",
+		"var_save":  "var_save [file]",
+		"help":      "help [convar]",
+		"bind":      "bind <key> [command]",
+		"if":        "if <convar> <op> <value> then <command>",
+		"addvar":    "addvar <convar> <amount>",
+		"scalevar":  "scalevar <convar> <factor>",
+	}
+	for name, usage := range usages {
+		if !skip[name] {
+			c.ConVar(name).SetUsage(usage)
+		}
+	}
+}
+
+// defaultConVarNames are the names of the convars registered by RegDefaultConVars, in the order of registration.
+// It must be kept in sync with RegDefaultConVars.
+var defaultConVarNames = []string{
+	"con_dump", "con_clear", "var_reset_all", "var_reset", "var_load", "var_save", "var_list",
+	"help", "bind", "showhidden", "addvar", "scalevar", "if",
+}
+
+func isDefaultConVar(name string) bool {
+	for _, other := range defaultConVarNames {
+		if name == other {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultConVarNames returns the names of the convars registered by RegDefaultConVars, sorted.
+func DefaultConVarNames() []string {
+	names := append([]string(nil), defaultConVarNames...)
+	sort.Strings(names)
+	return names
+}
+
+// UnknownFunc is the function signature of the unknown command handler.
+// cmd is the first token of the command and args are the remaining ones, both in their original case.
+type UnknownFunc func(cmd string, args []string) error
+
+// SetUnknownHandler sets a handler that is called when an executed command doesn't match any convar,
+// for ex. to send anything that is not a command as a chat message. The error of the handler is returned
+// to the caller. Commands loaded from config files never reach the handler.
+// Passing nil restores the default behavior of returning a not found error.
+func (c *Console) SetUnknownHandler(fn UnknownFunc) {
+	c.varLock.Lock()
+	defer c.varLock.Unlock()
+	c.unknown = fn
 }
 
 // RegConVar registers a new convar to be used in the console.
@@ -128,21 +478,210 @@ func (c *Console) RegConVar(cv *ConVar) {
 	c.varLock.Lock()
 	defer c.varLock.Unlock()
 	cv.console = c
+	cv.varName = c.fold(cv.rawName)
+	c.regSeq++
+	atomic.StoreInt32(&cv.regOrder, c.regSeq)
 	c.variables[cv.varName] = cv
 }
 
+// UnregConVar removes the convar with the given name from the console. It returns false if there is no such convar.
+// The convar itself keeps working, for ex. its typed setters still trigger its callback,
+// but it can no longer be executed, listed or saved.
+func (c *Console) UnregConVar(varName string) bool {
+	c.varLock.Lock()
+	defer c.varLock.Unlock()
+	varName = c.fold(varName)
+	cv, ok := c.variables[varName]
+	if !ok {
+		return false
+	}
+	atomic.StoreInt32(&cv.regOrder, 0)
+	delete(c.variables, varName)
+	return true
+}
+
+// RegFromMap registers a convar for each entry of defs, inferring its type from the value.
+// Convars are registered with an empty description and no callback. Boolean values are registered
+// as integer convars. Entries with an invalid name or an unsupported value type are skipped and
+// reported together in the returned error.
+func (c *Console) RegFromMap(defs map[string]interface{}) error {
+	names := make([]string, 0, len(defs))
+	for name := range defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []string
+	for _, name := range names {
+		value := defs[name]
+		if b, ok := value.(bool); ok {
+			value = 0
+			if b {
+				value = 1
+			}
+		}
+		if !validName(name) {
+			errs = append(errs, fmt.Sprintf(c.str().ErrInvalidName, name))
+			continue
+		}
+		if value == nil {
+			errs = append(errs, c.str().ErrNilValue)
+			continue
+		}
+		kind := reflect.TypeOf(value).Kind()
+		if !supportedType(kind) {
+			errs = append(errs, fmt.Sprintf(c.str().ErrUnsupportedType, kind))
+			continue
+		}
+		c.RegConVar(NewConVar(name, kind, false, "", value, nil))
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// MarkDeprecated marks oldName as deprecated in favor of newName.
+// Executing oldName logs a warning and executes newName instead. If oldName is also a registered convar,
+// it's excluded from Suggest and Save but it can still be accessed from Go.
+func (c *Console) MarkDeprecated(oldName, newName string) {
+	c.varLock.Lock()
+	defer c.varLock.Unlock()
+	c.deprecated[c.fold(oldName)] = c.fold(newName)
+}
+
+func (c *Console) isDeprecated(varName string) bool {
+	c.varLock.RLock()
+	defer c.varLock.RUnlock()
+	_, ok := c.deprecated[varName]
+	return ok
+}
+
 // ExecCmd parses and executes a console command string.
+// It returns the convar that the command resolved to. If the command is empty, whitespace only or
+// a comment (starting with "#"), nothing is executed and both the convar and the error are nil.
+// Callers must therefore nil-check the returned convar even when the error is nil.
+//
+// The command is split as described in ParseCommand. Everything after the name is the value of the convar,
+// and a string value that is a single quoted token is unquoted, for ex. `con_dump "my file.log"`.
+// A value of $name, for ex. `cl_backup_width $cl_width`, is replaced with the current value of the convar
+// of that name, which must be of the same type. A leading \$ stands for a literal $, as does a quoted "$name".
 func (c *Console) ExecCmd(cmd string) (*ConVar, error) {
-	return c.exec(false, cmd)
+	cv, _, err := c.exec(context.Background(), false, cmd)
+	return cv, err
+}
+
+// ExecCmdContext is like ExecCmd but passes ctx to the context-aware callback of the executed convar.
+// The command is not executed if ctx is already done. Note that callbacks can only be stopped cooperatively,
+// a callback that ignores ctx runs to completion. Non context-aware callbacks behave exactly as with ExecCmd.
+func (c *Console) ExecCmdContext(ctx context.Context, cmd string) (*ConVar, error) {
+	cv, _, err := c.exec(ctx, false, cmd)
+	return cv, err
+}
+
+// ExecCmdPriv is like ExecCmd but only executes the command if the privilege required by the convar, set via
+// ConVar.SetPrivilege, isn't higher than the given level. This also applies to any commands executed in turn,
+// for ex. by the if command, to the convars reset by var_reset and var_reset_all, and to the lines of the files
+// loaded by var_load. It lets a game expose a safe subset of convars to remote users.
+// All other ways of executing commands and the typed setters of the convars don't check privileges.
+func (c *Console) ExecCmdPriv(level int, cmd string) (*ConVar, error) {
+	ctx := context.WithValue(context.Background(), execStateKey{}, execState{limited: true, level: level})
+	cv, _, err := c.exec(ctx, false, cmd)
+	return cv, err
+}
+
+// Exec parses and executes a console command string for its side effects only.
+func (c *Console) Exec(cmd string) error {
+	_, _, err := c.exec(context.Background(), false, cmd)
+	return err
+}
+
+// ExecResult is the result of a single command executed by ExecAll.
+type ExecResult struct {
+	// ConVar is the convar that the command resolved to, nil for empty and comment commands.
+	ConVar *ConVar
+	// Changed is true if the command changed the value of the convar.
+	Changed bool
+	// Err is the error of the command, if any.
+	Err error
+}
+
+// ExecAll executes each of the given commands as if it's typed into the console, including func convars,
+// and returns a result per command. A failing command doesn't stop the execution of the following ones.
+func (c *Console) ExecAll(cmds []string) []ExecResult {
+	results := make([]ExecResult, len(cmds))
+	for i, cmd := range cmds {
+		cv, changed, err := c.exec(context.Background(), false, cmd)
+		results[i] = ExecResult{ConVar: cv, Changed: changed, Err: err}
+	}
+	return results
+}
+
+// ExecVar parses and executes a console command string like ExecCmd.
+// ok is true only if the command resolved to a convar, in which case the returned convar is never nil.
+func (c *Console) ExecVar(cmd string) (cv *ConVar, ok bool, err error) {
+	cv, _, err = c.exec(context.Background(), false, cmd)
+	return cv, cv != nil, err
+}
+
+// FlushCallbacks immediately triggers the pending callbacks of the convars with a debounce. See ConVar.SetCallbackDebounce.
+func (c *Console) FlushCallbacks() {
+	for _, cv := range c.ConVars() {
+		cv.flush()
+	}
 }
 
 // ResetAllVar resets all convars to their default values.
 // It doesn't trigger the set/update callback.
 func (c *Console) ResetAllVar() {
+	c.resetAll(execState{})
+}
+
+// resetAll resets the convars allowed by the execution state to their default values, see ResetAllVar.
+func (c *Console) resetAll(state execState) {
 	c.varLock.RLock()
 	defer c.varLock.RUnlock()
+	c.valLock.Lock()
+	defer c.valLock.Unlock()
+	for _, cv := range c.variables {
+		if !state.allows(cv) {
+			continue
+		}
+		cv.setLock.Lock()
+		if cv.value.Load() != cv.valDefault {
+			cv.value.Store(cv.valDefault)
+			c.recordChange(cv)
+		}
+		cv.setLock.Unlock()
+	}
+}
+
+// ResetAllVarNotify is like ResetAllVar but triggers the set/update callbacks of the convars whose value is changed.
+// The callbacks are triggered after all convars are reset and no locks are held, so they can use the console freely.
+func (c *Console) ResetAllVarNotify() {
+	type reset struct {
+		cv     *ConVar
+		oldVal interface{}
+	}
+	var resets []reset
+	c.varLock.RLock()
+	c.valLock.Lock()
 	for _, cv := range c.variables {
-		cv.value.Store(cv.valDefault)
+		cv.setLock.Lock()
+		if oldVal := cv.value.Load(); oldVal != cv.valDefault {
+			cv.value.Store(cv.valDefault)
+			c.recordChange(cv)
+			if !cv.isFunc && cv.getter == nil {
+				resets = append(resets, reset{cv: cv, oldVal: oldVal})
+			}
+		}
+		cv.setLock.Unlock()
+	}
+	c.valLock.Unlock()
+	c.varLock.RUnlock()
+
+	for _, r := range resets {
+		r.cv.changed(context.Background(), r.oldVal, r.cv.valDefault, false)
 	}
 }
 
@@ -150,13 +689,138 @@ func (c *Console) ResetAllVar() {
 func (c *Console) ConVar(varName string) *ConVar {
 	c.varLock.RLock()
 	defer c.varLock.RUnlock()
-	cv, ok := c.variables[strings.ToLower(varName)]
+	cv, ok := c.variables[c.fold(varName)]
 	if !ok {
 		return nil
 	}
 	return cv
 }
 
+// Exists reports whether a convar with the given name is registered.
+func (c *Console) Exists(varName string) bool {
+	c.varLock.RLock()
+	defer c.varLock.RUnlock()
+	_, ok := c.variables[c.fold(varName)]
+	return ok
+}
+
+func (c *Console) lookup(varName string) (*ConVar, error) {
+	cv := c.ConVar(varName)
+	if cv == nil {
+		return nil, fmt.Errorf(c.str().ErrVarNotFound, c.fold(varName))
+	}
+	return cv, nil
+}
+
+// GetBool returns the value of the convar with the given name as a boolean.
+func (c *Console) GetBool(varName string) (bool, error) {
+	cv, err := c.lookup(varName)
+	if err != nil {
+		return false, err
+	}
+	return cv.Bool()
+}
+
+// GetInt returns the value of the convar with the given name as an integer.
+func (c *Console) GetInt(varName string) (int, error) {
+	cv, err := c.lookup(varName)
+	if err != nil {
+		return 0, err
+	}
+	return cv.Int()
+}
+
+// GetFloat64 returns the value of the convar with the given name as a float64.
+func (c *Console) GetFloat64(varName string) (float64, error) {
+	cv, err := c.lookup(varName)
+	if err != nil {
+		return 0, err
+	}
+	return cv.Float64()
+}
+
+// GetString returns the value of the convar with the given name as a string.
+func (c *Console) GetString(varName string) (string, error) {
+	cv, err := c.lookup(varName)
+	if err != nil {
+		return "", err
+	}
+	return cv.StringVal()
+}
+
+// SetBool sets the convar with the given name from a boolean. See ConVar.SetBool.
+func (c *Console) SetBool(varName string, value bool) error {
+	cv, err := c.lookup(varName)
+	if err != nil {
+		return err
+	}
+	return cv.SetBool(value)
+}
+
+// SetInt sets the convar with the given name to the given int value.
+func (c *Console) SetInt(varName string, value int) error {
+	cv, err := c.lookup(varName)
+	if err != nil {
+		return err
+	}
+	return cv.SetInt(value)
+}
+
+// SetFloat64 sets the convar with the given name to the given float64 value.
+func (c *Console) SetFloat64(varName string, value float64) error {
+	cv, err := c.lookup(varName)
+	if err != nil {
+		return err
+	}
+	return cv.SetFloat64(value)
+}
+
+// SetString sets the convar with the given name to the given string value.
+func (c *Console) SetString(varName string, value string) error {
+	cv, err := c.lookup(varName)
+	if err != nil {
+		return err
+	}
+	return cv.SetString(value)
+}
+
+// ReadGroup returns the values of the convars with the given names as a consistent snapshot.
+// No value is stored while the snapshot is taken, so for ex. two convars that are only ever reset together
+// are never observed half reset. An error listing the unknown names is returned if any of them don't exist.
+// The values of computed convars are read after the snapshot is taken, since their getters are user code
+// that must run without holding any locks.
+func (c *Console) ReadGroup(varNames ...string) (map[string]interface{}, error) {
+	var (
+		values   = make(map[string]interface{}, len(varNames))
+		computed = make(map[string]*ConVar)
+		unknown  []string
+	)
+	c.varLock.RLock()
+	c.valLock.Lock()
+	for _, name := range varNames {
+		name = c.fold(name)
+		cv, ok := c.variables[name]
+		switch {
+		case !ok:
+			unknown = append(unknown, name)
+		case cv.getter != nil:
+			computed[name] = cv
+		default:
+			values[name] = cv.value.Load()
+		}
+	}
+	c.valLock.Unlock()
+	c.varLock.RUnlock()
+
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf(c.str().ErrVarsNotFound, strings.Join(unknown, ", "))
+	}
+	for name, cv := range computed {
+		values[name] = cv.getter()
+	}
+	return values, nil
+}
+
 // ConVars returns a slice of all registered convars.
 func (c *Console) ConVars() []*ConVar {
 	c.varLock.RLock()
@@ -168,19 +832,84 @@ func (c *Console) ConVars() []*ConVar {
 	return cvs
 }
 
+// ConVarsSorted returns a slice of all registered convars sorted by name.
+// Hidden convars are left out unless the showhidden convar is set. See ConVar.SetHidden.
+func (c *Console) ConVarsSorted() []*ConVar {
+	all := c.ConVars()
+	cvs := all[:0]
+	show := c.showHidden()
+	for _, cv := range all {
+		if show || !cv.IsHidden() {
+			cvs = append(cvs, cv)
+		}
+	}
+	sortByName(cvs)
+	return cvs
+}
+
+// showHidden reports whether hidden convars are listed and suggested, which is the case
+// if the showhidden convar registered by RegDefaultConVars is set.
+func (c *Console) showHidden() bool {
+	cv := c.ConVar("showhidden")
+	if cv == nil || cv.varType != reflect.Int {
+		return false
+	}
+	value, _ := cv.load().(int)
+	return value != 0
+}
+
+// ConVarsByRegOrder returns a slice of all registered convars in the order they are registered,
+// for ex. to list them grouped as the author registered them. See ConVar.RegOrder.
+func (c *Console) ConVarsByRegOrder() []*ConVar {
+	cvs := c.ConVars()
+	sort.Slice(cvs, func(i, j int) bool {
+		return cvs[i].RegOrder() < cvs[j].RegOrder()
+	})
+	return cvs
+}
+
+func sortByName(cvs []*ConVar) {
+	sort.Slice(cvs, func(i, j int) bool {
+		return cvs[i].varName < cvs[j].varName
+	})
+}
+
+// ForEachConVar calls fn for each registered convar in no particular order, without allocating a snapshot
+// like ConVars does. Iteration stops early if fn returns false.
+// The console's read lock is held during the iteration, so fn must not register convars or call other methods
+// that modify the set of convars, otherwise it deadlocks. This includes the callbacks of the convars set by fn.
+func (c *Console) ForEachConVar(fn func(cv *ConVar) bool) {
+	c.varLock.RLock()
+	defer c.varLock.RUnlock()
+	for _, cv := range c.variables {
+		if !fn(cv) {
+			return
+		}
+	}
+}
+
 // Suggest suggests a list of size n, populated with the convars that have the substring str in their names.
+// Like ConVarsSorted, it leaves out hidden convars unless the showhidden convar is set.
 func (c *Console) Suggest(str string, n int) []*ConVar {
+	return c.SuggestFilter(str, n, nil)
+}
+
+// SuggestFilter is like Suggest but only suggests the convars for which filter returns true,
+// for ex. only the ones that are not func convars. A nil filter allows all convars.
+func (c *Console) SuggestFilter(str string, n int, filter func(cv *ConVar) bool) []*ConVar {
 	var (
 		allCvs = c.ConVars()
 		cvs    []*ConVar
+		show   = c.showHidden()
 	)
-	if len([]rune(str)) < 3 {
-		// 3 feels like a good minimum number of runes to trigger a suggestion feature
-		// Open up an issue if you feel like it's not the best
+	if !c.suggestable(str) {
 		return cvs
 	}
 	for _, cv := range allCvs {
-		if strings.Contains(cv.varName, strings.ToLower(str)) {
+		if c.isDeprecated(cv.varName) || (cv.IsHidden() && !show) || (filter != nil && !filter(cv)) {
+			continue
+		}
+		if strings.Contains(cv.varName, c.fold(str)) {
 			cvs = append(cvs, cv)
 			if len(cvs) >= n {
 				return cvs
@@ -190,64 +919,340 @@ func (c *Console) Suggest(str string, n int) []*ConVar {
 	return cvs
 }
 
-func (c *Console) exec(fromFile bool, cmd string) (*ConVar, error) {
-	cmd = strings.TrimSpace(strings.ToLower(cmd))
-	tokens := strings.Fields(cmd)
-	lent := len(tokens)
-	if lent == 0 || tokens[0] == "#" {
+// defaultSuggestMinLen is the default minimum query length of the suggestions.
+// 3 feels like a good minimum number of runes to trigger a suggestion feature.
+const defaultSuggestMinLen = 3
+
+// SetSuggestMinLen sets the minimum number of runes, not bytes, that a query must have for Suggest and SuggestAll
+// to return any suggestions. The default is 3. Values smaller than 1 are treated as 1.
+func (c *Console) SetSuggestMinLen(n int) {
+	if n < 1 {
+		n = 1
+	}
+	atomic.StoreInt32(&c.suggestMinLen, int32(n))
+}
+
+// suggestable reports whether the query is long enough to trigger suggestions.
+func (c *Console) suggestable(str string) bool {
+	return utf8.RuneCountInString(str) >= int(atomic.LoadInt32(&c.suggestMinLen))
+}
+
+// SuggestAll is like Suggest but also matches the descriptions of the convars, case insensitively, to help
+// finding a convar by what it does. Convars with a matching name come first, each group is sorted by name.
+func (c *Console) SuggestAll(str string, n int) []*ConVar {
+	var byName, byDesc []*ConVar
+	if !c.suggestable(str) {
+		return nil
+	}
+	name, desc := c.fold(str), strings.ToLower(str)
+	for _, cv := range c.ConVarsSorted() {
+		if c.isDeprecated(cv.varName) {
+			continue
+		}
+		if strings.Contains(cv.varName, name) {
+			byName = append(byName, cv)
+		} else if strings.Contains(strings.ToLower(cv.varDesc), desc) {
+			byDesc = append(byDesc, cv)
+		}
+	}
+	cvs := append(byName, byDesc...)
+	if n < 0 {
+		n = 0
+	}
+	if len(cvs) > n {
+		cvs = cvs[:n]
+	}
+	return cvs
+}
+
+// Namespace returns the convars under the given dot separated prefix, sorted by name.
+// For ex. the prefix "graphics.shadows" matches "graphics.shadows" and "graphics.shadows.quality"
+// but not "graphics.shadowsfoo". Dots are regular characters otherwise and full names are used everywhere else.
+func (c *Console) Namespace(prefix string) []*ConVar {
+	prefix = strings.TrimSuffix(c.fold(prefix), ".")
+	c.varLock.RLock()
+	var cvs []*ConVar
+	for name, cv := range c.variables {
+		if prefix == "" || name == prefix || strings.HasPrefix(name, prefix+".") {
+			cvs = append(cvs, cv)
+		}
+	}
+	c.varLock.RUnlock()
+	sortByName(cvs)
+	return cvs
+}
+
+func (c *Console) exec(ctx context.Context, fromFile bool, cmd string) (cv *ConVar, changed bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	if !fromFile {
+		if !c.IsEnabled() {
+			return nil, false, ErrDisabled
+		}
+		if max := int(atomic.LoadInt32(&c.maxCmdLen)); max > 0 && utf8.RuneCountInString(cmd) > max {
+			return nil, false, fmt.Errorf(c.str().ErrCmdTooLong, max)
+		}
+		cmd = sanitize(cmd)
+	}
+
+	raw := strings.TrimSpace(cmd)
+	if fromFile && isSection(raw) {
+		// Section headers of INI-like config files are skipped
+		return nil, false, nil
+	}
+	cmd = c.fold(raw)
+	first, rest, err := splitCommand(cmd)
+	if err != nil {
+		return nil, false, c.tokenErr(err)
+	}
+	if first == "" {
 		// Empty command or comment line
-		return nil, nil
+		return nil, false, nil
 	}
+	argc := 1
+	if rest != "" {
+		argc = 2
+	}
+
+	state := stateFrom(ctx)
+	state.fromFile = fromFile
+	prefixExec := !fromFile && atomic.LoadInt32(&c.prefixExec) == 1
+	// showhidden is read before the lock is taken since reading it takes the lock too
+	show := prefixExec && c.showHidden()
 
 	c.varLock.RLock()
-	cv, ok := c.variables[tokens[0]]
+	name := first
+	newName, deprecated := c.deprecated[name]
+	if deprecated {
+		name = newName
+	}
+	cv, ok := c.variables[name]
+	var candidates []string
+	if !ok && prefixExec {
+		// Hidden convars and the ones above the privilege level can only be executed by their exact name,
+		// so that a prefix neither reveals them nor becomes ambiguous because of them
+		for other, ocv := range c.variables {
+			if strings.HasPrefix(other, name) && (show || !ocv.IsHidden()) && state.allows(ocv) {
+				candidates = append(candidates, other)
+			}
+		}
+		if len(candidates) == 1 {
+			cv, ok = c.variables[candidates[0]]
+		}
+	}
+	unknown := c.unknown
 	c.varLock.RUnlock()
+	if len(candidates) > 1 {
+		sort.Strings(candidates)
+		return nil, false, fmt.Errorf(c.str().ErrAmbiguous, name, strings.Join(candidates, ", "))
+	}
+	if deprecated {
+		c.LogWarningf(c.str().MsgDeprecated, first, newName)
+	}
 	if !ok {
-		return nil, fmt.Errorf(errVarNotFound, tokens[0])
+		if !fromFile && unknown != nil {
+			rawName, rawArgs, err := ParseCommand(raw)
+			if err != nil {
+				return nil, false, c.tokenErr(err)
+			}
+			return nil, false, unknown(rawName, rawArgs)
+		}
+		return nil, false, fmt.Errorf(c.str().ErrVarNotFound, name)
+	}
+
+	// If the command is executed from a file and it's a func then ignore it, unless it's safe to do so
+	if fromFile && cv.isFunc && !cv.fileSafe {
+		return nil, false, nil
 	}
 
-	// If the command is executed from a file and it's a func then ignore it
-	if fromFile && cv.isFunc {
-		return nil, nil
+	if !state.allows(cv) {
+		return nil, false, fmt.Errorf(c.str().ErrNoPrivilege, cv.varName)
 	}
 
 	var (
-		err    error
 		value  interface{}
 		valStr string
 	)
+	// fail records an invalid value as the last error of the convar, see ConVar.LastError
+	fail := func(err error) (*ConVar, bool, error) {
+		if !state.dryRun {
+			cv.setLastError(err)
+		}
+		return nil, false, err
+	}
 
-	if lent == 1 {
+	// Everything after the convar is considered part of the value of string convars
+	// A missing value evaluates to the default value for the func convars created by NewFunc and the like,
+	// to an empty string for string convars and to 0 for the others
+	valStr = rest
+	expand := cv.varType == reflect.String && atomic.LoadInt32(&c.expandEnv) == 1
+	ref := strings.HasPrefix(rest, "$")
+	if ref && expand && !c.Exists(rest[1:]) {
+		// Not a convar, so it's left to the environment variable expansion below
+		ref = false
+	}
+	switch {
+	case cv.isGreedy():
+		// The remainder is taken from the command as it's given, before it's lowercased
+		_, rawRest, _ := splitCommand(raw)
+		value = strings.TrimSpace(rawRest)
+	case cv.useDefault && argc == 1:
+		value = cv.valDefault
+	case ref:
+		// The value refers to the current value of another convar
+		if value, err = c.refValue(rest[1:], cv.varType); err != nil {
+			return fail(err)
+		}
+	case strings.HasPrefix(rest, `\$`):
+		valStr = rest[1:]
+		expand = false
+	case cv.varType == reflect.String:
+		valStr = unquoteValue(rest)
+	case argc == 1:
 		valStr = "0"
-	} else {
-		valStr = strings.TrimSpace(cmd[len(tokens[0]):])
+	default:
+		// Numeric values are a single token, so irregular spacing doesn't break the conversion. Anything after it
+		// must be a comment, like in a hand-edited config file, otherwise cl_width 800 600 would set 800
+		fields := strings.Fields(rest)
+		valStr = fields[0]
+		if len(fields) > 1 && !strings.HasPrefix(fields[1], "#") {
+			return fail(fmt.Errorf(c.str().ErrBadStringConversion, rest, cv.varType))
+		}
 	}
 
-	switch cv.varType {
-	case reflect.Bool, reflect.Int:
-		value, err = strconv.Atoi(valStr)
-	case reflect.Float64:
-		value, err = strconv.ParseFloat(valStr, 64)
-	case reflect.String:
-		// Everything after the convar is considered part of the string
-		// This also evaluates to an empty string in case of no value is put
-		if lent == 1 {
-			valStr = ""
+	if cv.isBool() {
+		if strings.EqualFold(valStr, "true") {
+			valStr = "1"
+		} else if strings.EqualFold(valStr, "false") {
+			valStr = "0"
 		}
-		value = strings.TrimSpace(cmd[len(tokens[0]):])
 	}
-	if err != nil {
-		return nil, fmt.Errorf(errBadStringConversion, valStr, cv.varType)
+	if value == nil && expand {
+		valStr = os.Expand(valStr, lookupEnv)
+	}
+	if value == nil {
+		parse := c.parser(cv.varType)
+		if parse == nil {
+			return nil, false, fmt.Errorf(c.str().ErrUnsupportedType, cv.varType)
+		}
+		value, err = parse(valStr)
+		if errors.Is(err, strconv.ErrRange) {
+			return fail(fmt.Errorf(c.str().ErrNumRange, valStr, cv.varType))
+		}
+		if err != nil {
+			return fail(fmt.Errorf(c.str().ErrBadStringConversion, valStr, cv.varType))
+		}
 	}
+	value = cv.canonical(value)
 
-	// ex: write will apply below rules
+	// ex: below rules are applied
 	// cl_reload		(func)	run function with new value 'default', don't set any value
 	// cl_reload	10	(func)	run function with new value 10, don't set any value
 	// cl_width			(var)	don't run function, don't set any value
 	// cl_width		10	(var)	run function with new value 10, set value to 10
-	err = cv.write(cv.varType, value, lent)
+	switch {
+	case !cv.isFunc && argc == 1 && cv.isWriteOnly():
+		return nil, false, fmt.Errorf(c.str().ErrWriteOnly, cv.varName)
+	case cv.isFunc:
+		if cv.needsArg && (argc == 1 || value == "") {
+			return fail(fmt.Errorf(c.str().ErrRequiresArg, cv.varName))
+		}
+		if err = cv.check(cv.varType, value); err != nil {
+			return fail(err)
+		}
+		if state.dryRun {
+			return cv, false, nil
+		}
+		cv.setLastError(nil)
+		// Nested executions from within the callback inherit the state of this one
+		cv.invokeFunc(context.WithValue(ctx, execStateKey{}, state), value)
+	case argc > 1:
+		if err = cv.check(cv.varType, value); err != nil {
+			return fail(err)
+		}
+		if state.dryRun {
+			if cv.getter != nil {
+				return nil, false, fmt.Errorf(c.str().ErrReadOnly, cv.varName)
+			}
+			return cv, false, nil
+		}
+		if changed, err = cv.setValue(ctx, value, false); err != nil {
+			return fail(err)
+		}
+		cv.setLastError(nil)
+	}
+	if state.out != nil && !cv.isFunc && argc == 1 {
+		state.out.add(c.queryLine(cv))
+	}
+	if !fromFile && !cv.isFunc && !state.dryRun && !cv.isWriteOnly() && atomic.LoadInt32(&c.echo) == 1 {
+		if argc == 1 {
+			c.LogPrintf("%s", c.queryLine(cv))
+		} else {
+			c.LogPrintf("%s %s", cv.varName, cv.DisplayValue())
+		}
+	}
+	return cv, changed, nil
+}
+
+// lookupEnv returns the value of the environment variable with the given name, ignoring case
+// since commands are lowercased before they are executed.
+func lookupEnv(name string) string {
+	if value, ok := os.LookupEnv(name); ok {
+		return value
+	}
+	for _, env := range os.Environ() {
+		if i := strings.IndexByte(env, '='); i > 0 && strings.EqualFold(env[:i], name) {
+			return env[i+1:]
+		}
+	}
+	return ""
+}
+
+// refValue returns the current value of the convar referenced by a $name value, which must be of the given type.
+func (c *Console) refValue(refName string, kind reflect.Kind) (interface{}, error) {
+	ref, err := c.lookup(refName)
 	if err != nil {
 		return nil, err
 	}
-	return cv, nil
+	if ref.isWriteOnly() {
+		return nil, fmt.Errorf(c.str().ErrWriteOnly, ref.varName)
+	}
+	if ref.varType != kind {
+		return nil, fmt.Errorf(c.str().ErrVarBadType, ref.varName, kind)
+	}
+	return ref.load(), nil
+}
+
+// execState is the state of an execution, which is passed to func convar callbacks via the context
+// so that the commands they execute in turn are treated the same way.
+type execState struct {
+	fromFile bool
+	limited  bool // Whether the execution is limited to the convars up to a privilege level
+	level    int
+	dryRun   bool    // Whether the command is only validated, without invoking or setting the convar
+	out      *output // Collects the output of the commands if it's not nil, see ExecCmdOutput
+}
+
+type execStateKey struct{}
+
+// allows reports whether the execution is allowed to change the given convar, see ExecCmdPriv.
+func (s execState) allows(cv *ConVar) bool {
+	return !s.limited || cv.Privilege() <= s.level
+}
+
+// stateFrom returns the execution state carried by ctx, or the state of an interactive execution if there is none.
+func stateFrom(ctx context.Context) execState {
+	state, _ := ctx.Value(execStateKey{}).(execState)
+	return state
+}
+
+// sanitize removes the control characters from cmd except for whitespace.
+func sanitize(cmd string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && !unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, cmd)
 }
@@ -0,0 +1,234 @@
+// Copyright © 2020 Cosku Bas. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package convar
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// newTestConsole returns a console with the default convars and a privileged sv_cheats convar set to 1.
+func newTestConsole(t *testing.T) (*Console, *ConVar) {
+	t.Helper()
+	c := NewConsole(100, LogError, "", "", "")
+	c.RegDefaultConVars()
+	cheats := NewConVar("sv_cheats", reflect.Int, false, "", 0, nil)
+	cheats.SetPrivilege(10)
+	c.RegConVar(cheats)
+	if err := cheats.SetInt(1); err != nil {
+		t.Fatal(err)
+	}
+	return c, cheats
+}
+
+func TestExecCmdPrivNested(t *testing.T) {
+	// Commands are lowercased, so the path must be in lowercase too
+	dir, err := ioutil.TempDir("", "convar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cheats.ini")
+	if err := ioutil.WriteFile(file, []byte("sv_cheats 5\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cmd := range []string{"sv_cheats 0", "var_reset sv_cheats", "var_reset_all", "var_load " + file} {
+		c, cheats := newTestConsole(t)
+		c.ExecCmdPriv(0, cmd)
+		if got := cheats.MustInt(); got != 1 {
+			t.Errorf("%q with level 0: sv_cheats = %d, want 1", cmd, got)
+		}
+		c.ExecCmdPriv(10, cmd)
+		if got := cheats.MustInt(); got == 1 {
+			t.Errorf("%q with level 10: sv_cheats is not changed", cmd)
+		}
+	}
+}
+
+func TestConVarsSortedOrder(t *testing.T) {
+	var first string
+	for i := 0; i < 10; i++ {
+		c := NewConsole(100, LogError, "", "", "")
+		c.RegDefaultConVars()
+		for _, name := range []string{"sv_gravity", "cl_width", "r_fov", "cl_height", "a"} {
+			c.RegConVar(NewConVar(name, reflect.Int, false, "", 0, nil))
+		}
+
+		cvs := c.ConVarsSorted()
+		names := make([]string, len(cvs))
+		for j, cv := range cvs {
+			names[j] = cv.Name()
+		}
+		if !sort.StringsAreSorted(names) {
+			t.Fatalf("ConVarsSorted is not sorted: %q", names)
+		}
+
+		_, out, err := c.ExecCmdOutput("var_list")
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(out, "\n")
+		if len(lines) != len(names) {
+			t.Fatalf("var_list printed %d lines, want %d", len(lines), len(names))
+		}
+		for j, line := range lines {
+			if !strings.HasPrefix(line, names[j]+" ") {
+				t.Fatalf("var_list line %d is %q, want %s first", j, line, names[j])
+			}
+		}
+		if i == 0 {
+			first = out
+		} else if out != first {
+			t.Fatalf("var_list output differs between runs:\n%s\n---\n%s", first, out)
+		}
+	}
+}
+
+func TestSuggestMultibyte(t *testing.T) {
+	c := NewConsole(100, LogError, "", "", "")
+	for _, name := range []string{"ünlü_ışık", "ışık_gücü", "işlem"} {
+		c.RegConVar(NewConVar(name, reflect.Int, false, "Işık ayarı", 0, nil))
+	}
+
+	// "ış" is 2 runes but 4 bytes, so it's too short for the default minimum of 3 runes
+	if got := c.Suggest("ış", 10); len(got) != 0 {
+		t.Errorf("Suggest(ış) with the default minimum = %d convars, want 0", len(got))
+	}
+	c.SetSuggestMinLen(2)
+	if got := c.Suggest("ış", 10); len(got) != 2 {
+		t.Errorf("Suggest(ış) = %d convars, want 2", len(got))
+	}
+	if got := c.SuggestAll("ış", 10); len(got) != 2 {
+		t.Errorf("SuggestAll(ış) = %d convars, want 2", len(got))
+	}
+	c.SetSuggestMinLen(4)
+	if got := c.Suggest("ışık", 10); len(got) != 2 {
+		t.Errorf("Suggest(ışık) with a minimum of 4 = %d convars, want 2", len(got))
+	}
+	if got := c.Suggest("ışı", 10); len(got) != 0 {
+		t.Errorf("Suggest(ışı) with a minimum of 4 = %d convars, want 0", len(got))
+	}
+}
+
+func TestCaseSensitivity(t *testing.T) {
+	for _, sensitive := range []bool{false, true} {
+		c := NewConsole(100, LogError, "", "", "")
+		c.SetCaseSensitive(sensitive)
+		name := NewConVar("Name", reflect.String, false, "", "", nil)
+		width := NewConVar("cl_Width", reflect.Int, false, "", 640, nil)
+		c.RegConVar(name)
+		c.RegConVar(width)
+
+		_, err := c.ExecCmd("Name Gordon Freeman")
+		if err != nil {
+			t.Fatalf("sensitive %v: %v", sensitive, err)
+		}
+		_, lowerErr := c.ExecCmd("cl_width 800")
+		_, exactErr := c.ExecCmd("cl_Width 1024")
+		if exactErr != nil {
+			t.Errorf("sensitive %v: cl_Width 1024: %v", sensitive, exactErr)
+		}
+
+		if sensitive {
+			if got := name.MustString(); got != "Gordon Freeman" {
+				t.Errorf("case-sensitive Name = %q, want Gordon Freeman", got)
+			}
+			if lowerErr == nil {
+				t.Error("case-sensitive cl_width is found")
+			}
+			if c.Exists("name") || !c.Exists("Name") {
+				t.Error("case-sensitive lookup ignores case")
+			}
+		} else {
+			if got := name.MustString(); got != "gordon freeman" {
+				t.Errorf("case-insensitive name = %q, want gordon freeman", got)
+			}
+			if lowerErr != nil {
+				t.Errorf("case-insensitive cl_width 800: %v", lowerErr)
+			}
+			if !c.Exists("NAME") || !c.Exists("name") {
+				t.Error("case-insensitive lookup doesn't ignore case")
+			}
+		}
+		if got := width.MustInt(); got != 1024 {
+			t.Errorf("sensitive %v: cl_Width = %d, want 1024", sensitive, got)
+		}
+	}
+}
+
+func TestDefaultConVarNames(t *testing.T) {
+	c := NewConsole(100, LogError, "", "", "")
+	c.RegDefaultConVars()
+	var got []string
+	for _, cv := range c.ConVarsByRegOrder() {
+		got = append(got, cv.Name())
+	}
+	if !reflect.DeepEqual(got, defaultConVarNames) {
+		t.Errorf("RegDefaultConVars registers %q, DefaultConVarNames lists %q", got, defaultConVarNames)
+	}
+	sort.Strings(got)
+	if names := DefaultConVarNames(); !reflect.DeepEqual(names, got) {
+		t.Errorf("DefaultConVarNames = %q, want %q", names, got)
+	}
+
+	// Every name can be skipped
+	for _, name := range defaultConVarNames {
+		c := NewConsole(100, LogError, "", "", "")
+		c.RegDefaultConVarsExcept(name)
+		if c.Exists(name) {
+			t.Errorf("RegDefaultConVarsExcept(%q) registers it", name)
+		}
+		if n := len(c.ConVars()); n != len(defaultConVarNames)-1 {
+			t.Errorf("RegDefaultConVarsExcept(%q) registers %d convars, want %d", name, n, len(defaultConVarNames)-1)
+		}
+	}
+}
+
+func TestPrefixExecFilter(t *testing.T) {
+	c, cheats := newTestConsole(t)
+	c.SetPrefixExec(true)
+	secret := NewConVar("sv_secret", reflect.Int, false, "", 0, nil)
+	secret.SetHidden(true)
+	c.RegConVar(secret)
+	c.RegConVar(NewConVar("sv_gravity", reflect.Int, false, "", 800, nil))
+
+	// Only sv_gravity is visible to an unprivileged caller
+	if cv, err := c.ExecCmdPriv(0, "sv_ 0"); err != nil || cv == nil || cv.Name() != "sv_gravity" {
+		t.Errorf("sv_ with level 0 executes %v, %v, want sv_gravity", cv, err)
+	}
+	if _, err := c.ExecCmdPriv(0, "sv_ch 0"); err == nil {
+		t.Error("sv_ch with level 0 doesn't fail")
+	} else if strings.Contains(err.Error(), "sv_cheats") {
+		t.Errorf("sv_ch with level 0 reveals sv_cheats: %v", err)
+	}
+	if _, err := c.ExecCmdPriv(0, "sv_se 1"); err == nil {
+		t.Error("sv_se executes the hidden sv_secret")
+	}
+	if got := cheats.MustInt(); got != 1 {
+		t.Errorf("sv_cheats = %d, want 1", got)
+	}
+
+	// The error of an ambiguous prefix only lists the visible candidates
+	_, err := c.ExecCmdPriv(10, "sv_ 0")
+	if err == nil || !strings.Contains(err.Error(), "sv_cheats, sv_gravity") || strings.Contains(err.Error(), "sv_secret") {
+		t.Errorf("sv_ with level 10: error = %v, want one listing sv_cheats and sv_gravity", err)
+	}
+
+	// showhidden makes the hidden convars matchable again
+	c.ExecCmd("showhidden 1")
+	if cv, err := c.ExecCmd("sv_se 1"); err != nil || cv != secret {
+		t.Errorf("sv_se with showhidden executes %v, %v, want sv_secret", cv, err)
+	}
+	// Exact names work regardless
+	if cv, err := c.ExecCmdPriv(0, "sv_secret 2"); err != nil || cv != secret {
+		t.Errorf("sv_secret executes %v, %v", cv, err)
+	}
+}
@@ -5,28 +5,71 @@
 package convar
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
+	"unicode"
 )
 
 // ConVar represents a console variable.
+//
+// The value of a convar can be set from multiple goroutines. Each callback receives a consistent pair of the old
+// and the new value, but callbacks run after the value is stored and without holding any locks, so the callbacks
+// of concurrent setters can run in any order and concurrently. The last callback to run doesn't necessarily report
+// the current value, which should be read from the convar itself if it matters.
 type ConVar struct {
+	reads      uint64 // Accessed atomically, kept first for 64-bit alignment
+	writes     uint64 // Accessed atomically
 	console    *Console
 	varName    string
+	rawName    string // Name as given to NewConVar, used as the name in case-sensitive mode
 	varType    reflect.Kind
 	varDesc    string
 	value      atomic.Value
 	valDefault interface{}
 	valSet     ValSetFunc
+	valSetCtx  ValSetContextFunc
+	getter     func() interface{}
 	isFunc     bool
+	fileSafe   bool
+	needsArg   bool
+	useDefault bool // Whether a missing value evaluates to the default, see NewFunc
+	greedy     int32
+	writeOnly  int32
+	privilege  int32
+	asBool     int32
+	hidden     int32
+	regOrder   int32
+	setLock    sync.Mutex
+	metaLock   sync.RWMutex
+	valMin     interface{}
+	valMax     interface{}
+	valStep    interface{}
+	category   string
+	usage      string
+	options    []string // Allowed values of an enum convar, never changed after creation
+	cbLock     sync.Mutex
+	debounce   time.Duration
+	timer      *time.Timer
+	pending    bool
+	pendingOld interface{}
+	forced     bool         // Whether a pending debounced callback is triggered even without a change
+	lastErr    atomic.Value // Holds a lastError
 }
 
-// NewConVar returns a convar of the given name and type. Convar names are case insensitive.
+// NewConVar returns a convar of the given name and type. Convar names are case insensitive, unless the
+// console is in case-sensitive mode. See Console.SetCaseSensitive.
 // varDefault is the default value.
 // varDesc is the description of the convar.
-// valSet is a callback function that is triggered everytime the convar's value is changed.
+// valSet is a callback function that is triggered everytime the convar's value is changed. It can be nil,
+// for ex. for convars that only hold a value.
 //
 // NewConVar will panic if there are any errors.
 // NewConVar should ideally be called for each convar at the begging of the application and before loading a config file.
@@ -36,18 +79,28 @@ type ConVar struct {
 // 		Convar is not saved to or loaded from the config file. This can be used to protect users from doing things like cyclic loading.
 // 		SetInt, SetBool, SetFloat64, SetString functions do not change the value but instead trigger the callback with the given value.
 // 		Value is always equal to default value.
+// 		The default value is the old value passed to the callback on every execution. Executing the convar without
+// 		a value passes an empty string for string convars and 0 for the others, see NewFunc to pass the default instead.
 func NewConVar(varName string, varType reflect.Kind, isFunc bool, varDesc string, valDefault interface{}, valSet ValSetFunc) *ConVar {
+	rawName := varName
 	varName = strings.ToLower(varName)
+	if valDefault == nil {
+		panic(fmt.Errorf(errNilDefault, varName))
+	}
 	if varType != reflect.TypeOf(valDefault).Kind() {
 		// Type of valDefault and the given varType don't match
 		// We panic here because ideally RegVar should be called once at the beggining
 		panic(fmt.Errorf(errTypeMismatch, valDefault, varName, varType))
 	}
-	if !(varType == reflect.Int || varType == reflect.Float64 || varType == reflect.String) {
+	if !supportedType(varType) {
 		panic(fmt.Errorf(errUnsupportedType, varType))
 	}
+	if f, ok := valDefault.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		panic(fmt.Errorf(errNotFinite, valDefault, varName))
+	}
 	cv := &ConVar{
 		varName:    varName,
+		rawName:    rawName,
 		varType:    varType,
 		varDesc:    varDesc,
 		valDefault: valDefault,
@@ -58,107 +111,454 @@ func NewConVar(varName string, varType reflect.Kind, isFunc bool, varDesc string
 	return cv
 }
 
+// NewFunc returns a func convar like NewConVar with isFunc set to true. If requiresArg is true, executing the
+// convar without a value or with an empty string results in an error and the callback is not triggered,
+// so the callback doesn't need to check for a missing argument. Otherwise executing the convar without a value
+// passes the default value to the callback, unlike NewConVar.
+func NewFunc(varName string, varType reflect.Kind, requiresArg bool, varDesc string, valDefault interface{}, valSet ValSetFunc) *ConVar {
+	cv := NewConVar(varName, varType, true, varDesc, valDefault, valSet)
+	cv.needsArg = requiresArg
+	cv.useDefault = true
+	return cv
+}
+
+// NewFuncContext is like NewFunc but takes a callback that receives the context of the execution,
+// like NewConVarContext.
+func NewFuncContext(varName string, varType reflect.Kind, requiresArg bool, varDesc string, valDefault interface{}, valSet ValSetContextFunc) *ConVar {
+	cv := NewConVarContext(varName, varType, true, varDesc, valDefault, valSet)
+	cv.needsArg = requiresArg
+	cv.useDefault = true
+	return cv
+}
+
+// NewCmd returns a func convar that takes no value, for ex. a quit command. fn is called every time the command
+// is executed, any value given to it is ignored.
+func NewCmd(varName string, varDesc string, fn func(con *Console)) *ConVar {
+	return NewFunc(varName, reflect.String, false, varDesc, "", func(con *Console, oldVal, newVal interface{}) {
+		fn(con)
+	})
+}
+
+// NewRawCmd returns a func convar that receives the remainder of the command line after its name verbatim,
+// only trimmed of the surrounding whitespace, for ex. for a chat command like say. It's a greedy convar,
+// see Greedy.
+func NewRawCmd(varName string, varDesc string, fn func(con *Console, raw string)) *ConVar {
+	return NewConVar(varName, reflect.String, true, varDesc, "", func(con *Console, oldVal, newVal interface{}) {
+		fn(con, newVal.(string))
+	}).Greedy()
+}
+
+// supportedType reports whether a convar can be created with the given type.
+func supportedType(kind reflect.Kind) bool {
+	return kind == reflect.Int || kind == reflect.Float64 || kind == reflect.String
+}
+
+// validName reports whether the given name can be executed as a command.
+// A valid name is a single non-empty token that doesn't start a comment.
+func validName(name string) bool {
+	fields := strings.Fields(name)
+	return len(fields) == 1 && fields[0] == name && !strings.HasPrefix(name, "#")
+}
+
+// typeNames maps the supported convar types to their human-readable labels.
+var typeNames = map[reflect.Kind]string{
+	reflect.Int:     "Integer",
+	reflect.Float64: "Decimal",
+	reflect.String:  "Text",
+}
+
+// NewComputedConVar returns a read-only convar whose value is computed by getter on every read, for ex. the
+// current frame rate. getter must return a value of the given type. Setting a computed convar, from Go or from
+// the console, results in an error, and computed convars are never saved to config files.
+// NewComputedConVar panics if the type is not supported.
+func NewComputedConVar(varName string, varType reflect.Kind, varDesc string, getter func() interface{}) *ConVar {
+	var valDefault interface{}
+	switch varType {
+	case reflect.Int:
+		valDefault = 0
+	case reflect.Float64:
+		valDefault = 0.0
+	case reflect.String:
+		valDefault = ""
+	default:
+		panic(fmt.Errorf(errUnsupportedType, varType))
+	}
+	cv := NewConVar(varName, varType, false, varDesc, valDefault, nil)
+	cv.getter = getter
+	return cv
+}
+
 // ValSetFunc is the function signature of the value set/update callback.
 type ValSetFunc func(con *Console, oldVal, newVal interface{})
 
-func (cv *ConVar) write(varType reflect.Kind, value interface{}, argc int) error {
+// ValSetContextFunc is the function signature of a context-aware value set/update callback.
+// ctx is the context given to ExecCmdContext, or context.Background() when there is no such context.
+type ValSetContextFunc func(ctx context.Context, con *Console, oldVal, newVal interface{})
+
+// NewConVarContext is like NewConVar but takes a context-aware callback, for ex. to be able to cancel
+// a long running func convar that is executed via ExecCmdContext.
+func NewConVarContext(varName string, varType reflect.Kind, isFunc bool, varDesc string, valDefault interface{}, valSet ValSetContextFunc) *ConVar {
+	cv := NewConVar(varName, varType, isFunc, varDesc, valDefault, nil)
+	cv.valSetCtx = valSet
+	return cv
+}
+
+// check validates the given value of the given type before it's used for the convar.
+func (cv *ConVar) check(varType reflect.Kind, value interface{}) error {
 	if value == nil {
-		return fmt.Errorf(errNilValue)
+		return errors.New(cv.console.str().ErrNilValue)
 	}
 
 	if varType != reflect.TypeOf(value).Kind() {
 		// Type of value and given varType don't match
-		return fmt.Errorf(errTypeMismatch, value, cv.varName, varType)
+		return fmt.Errorf(cv.console.str().ErrTypeMismatch, value, cv.varName, varType)
 	}
 
 	if cv.varType != varType {
 		// Type of the found convar doesn't match with the given varType
-		return fmt.Errorf(errVarBadType, cv.varName, varType)
+		return fmt.Errorf(cv.console.str().ErrVarBadType, cv.varName, varType)
+	}
+	if f, ok := value.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		// NaN would never compare equal to itself, so it would be treated as a change on every set
+		return fmt.Errorf(cv.console.str().ErrNotFinite, value, cv.varName)
+	}
+	if err := cv.checkOption(value); err != nil {
+		return err
 	}
+	return cv.checkRange(value)
+}
+
+// lastError wraps the last error of a convar since atomic.Value can't store nil or values of different types.
+type lastError struct {
+	err error
+}
 
+// LastError returns the error of the last attempt to set or invoke the convar, for ex. an out of range value,
+// or nil if the last attempt succeeded. Queries don't affect it.
+func (cv *ConVar) LastError() error {
+	le, _ := cv.lastErr.Load().(lastError)
+	return le.err
+}
+
+func (cv *ConVar) setLastError(err error) {
+	cv.lastErr.Store(lastError{err: err})
+}
+
+// apply invokes a func convar or sets the value of any other convar, which is what the typed setters do.
+func (cv *ConVar) apply(ctx context.Context, varType reflect.Kind, value interface{}) (changed bool, err error) {
+	return cv.applyValue(ctx, varType, value, false)
+}
+
+// applyValue is like apply but if force is true, the value is stored and the callback is triggered
+// even if the value is not changed.
+func (cv *ConVar) applyValue(ctx context.Context, varType reflect.Kind, value interface{}, force bool) (changed bool, err error) {
+	value = cv.canonical(value)
+	if err := cv.check(varType, value); err != nil {
+		cv.setLastError(err)
+		return false, err
+	}
 	if cv.isFunc {
-		cv.valSet(cv.console, cv.valDefault, value)
-		return nil
+		cv.setLastError(nil)
+		cv.invokeFunc(ctx, value)
+		return false, nil
 	}
+	changed, err = cv.setValue(ctx, value, force)
+	cv.setLastError(err)
+	return changed, err
+}
 
-	// If no argument was given and convar is not a function, we don't set the value
-	if argc < 2 {
-		return nil
+// invokeFunc triggers the callback of a func convar with the given value, without changing its value.
+// The value must be validated via check beforehand.
+func (cv *ConVar) invokeFunc(ctx context.Context, value interface{}) {
+	cv.countWrite()
+	cv.callback(ctx, cv.valDefault, value)
+}
+
+// setValue sets the value of a non-func convar and triggers its callback if the value is changed, or always if
+// force is true. The value must be validated via check beforehand.
+func (cv *ConVar) setValue(ctx context.Context, value interface{}, force bool) (changed bool, err error) {
+	if cv.getter != nil {
+		return false, fmt.Errorf(cv.console.str().ErrReadOnly, cv.varName)
 	}
 
+	// The old value is loaded and the new one is stored under the lock so that the callback always
+	// receives a consistent pair, even if another goroutine is setting or resetting the convar
+	unlock := cv.lock()
 	oldVal := cv.value.Load()
-	if oldVal == value {
+	if oldVal == value && !force {
 		// Silently stop if the old and new values are the same
-		return nil
+		unlock()
+		return false, nil
 	}
 	cv.value.Store(value)
-	cv.valSet(cv.console, oldVal, value)
-	return nil
+	unlock()
+	changed = oldVal != value
+	if changed {
+		cv.console.recordChange(cv)
+	}
+	cv.countWrite()
+	cv.changed(ctx, oldVal, value, force)
+	return changed, nil
+}
+
+// changed triggers the callback after the value is changed, unless it's debounced or suppressed.
+// If force is true, the debounced or suppressed callback is triggered even if the value ends up unchanged,
+// see ForceSet.
+func (cv *ConVar) changed(ctx context.Context, oldVal, newVal interface{}, force bool) {
+	if cv.console.suppressed(cv, oldVal, force) {
+		return
+	}
+	cv.cbLock.Lock()
+	if cv.debounce <= 0 {
+		cv.cbLock.Unlock()
+		cv.callback(ctx, oldVal, newVal)
+		return
+	}
+	if cv.pending {
+		cv.timer.Reset(cv.debounce)
+	} else {
+		cv.pending = true
+		cv.pendingOld = oldVal
+		cv.timer = time.AfterFunc(cv.debounce, cv.flush)
+	}
+	cv.forced = cv.forced || force
+	cv.cbLock.Unlock()
+}
+
+// flush triggers the pending debounced callback, if any.
+// The callback receives the value before the first pending change and the current value.
+func (cv *ConVar) flush() {
+	cv.cbLock.Lock()
+	if !cv.pending {
+		cv.cbLock.Unlock()
+		return
+	}
+	cv.timer.Stop()
+	oldVal, force := cv.pendingOld, cv.forced
+	cv.pending, cv.pendingOld, cv.forced = false, nil, false
+	cv.cbLock.Unlock()
+
+	if newVal := cv.value.Load(); newVal != oldVal || force {
+		cv.callback(context.Background(), oldVal, newVal)
+	}
+}
+
+// callback triggers the value set/update callback of the convar, if it has one.
+func (cv *ConVar) callback(ctx context.Context, oldVal, newVal interface{}) {
+	if cv.valSetCtx != nil {
+		cv.valSetCtx(ctx, cv.console, oldVal, newVal)
+		return
+	}
+	if cv.valSet != nil {
+		cv.valSet(cv.console, oldVal, newVal)
+	}
+}
+
+// load returns the value of the convar and counts the read if stats are enabled.
+func (cv *ConVar) load() interface{} {
+	if cv.console.statsEnabled() {
+		atomic.AddUint64(&cv.reads, 1)
+	}
+	return cv.get()
+}
+
+// get returns the value of the convar, which is computed for the convars created by NewComputedConVar.
+func (cv *ConVar) get() interface{} {
+	if cv.getter != nil {
+		return cv.getter()
+	}
+	return cv.value.Load()
+}
+
+// countWrite counts a successful write if stats are enabled.
+func (cv *ConVar) countWrite() {
+	if cv.console.statsEnabled() {
+		atomic.AddUint64(&cv.writes, 1)
+	}
+}
+
+// Stats returns the number of times the value of the convar is read via its accessors and successfully written,
+// while stats are enabled on its console. See Console.EnableStats.
+func (cv *ConVar) Stats() (reads, writes uint64) {
+	return atomic.LoadUint64(&cv.reads), atomic.LoadUint64(&cv.writes)
 }
 
 // Bool returns the value of the convar as a boolean. The underlying type for a boolean is integer.
 func (cv *ConVar) Bool() (bool, error) {
-	value := cv.value.Load()
+	value := cv.load()
 	if reflect.TypeOf(value).Kind() != reflect.Int {
-		return false, fmt.Errorf(errVarBadType, cv.varName, reflect.Int)
+		return false, fmt.Errorf(cv.console.str().ErrVarBadType, cv.varName, reflect.Int)
 	}
 	return value.(int) == 1, nil
 }
 
 // Int returns the value of the convar as an integer.
 func (cv *ConVar) Int() (int, error) {
-	value := cv.value.Load()
+	value := cv.load()
 	if reflect.TypeOf(value).Kind() != reflect.Int {
-		return 0, fmt.Errorf(errVarBadType, cv.varName, reflect.Int)
+		return 0, fmt.Errorf(cv.console.str().ErrVarBadType, cv.varName, reflect.Int)
 	}
 	return value.(int), nil
 }
 
 // Float64 returns the value of the convar as a float64.
 func (cv *ConVar) Float64() (float64, error) {
-	value := cv.value.Load()
+	value := cv.load()
 	if reflect.TypeOf(value).Kind() != reflect.Float64 {
-		return 0, fmt.Errorf(errVarBadType, cv.varName, reflect.Float64)
+		return 0, fmt.Errorf(cv.console.str().ErrVarBadType, cv.varName, reflect.Float64)
 	}
 	return value.(float64), nil
 }
 
-// String returns the value of the convar as a string.
-func (cv *ConVar) String() (string, error) {
-	value := cv.value.Load()
+// StringVal returns the value of the convar as a string.
+func (cv *ConVar) StringVal() (string, error) {
+	value := cv.load()
 	if reflect.TypeOf(value).Kind() != reflect.String {
-		return "", fmt.Errorf(errVarBadType, cv.varName, reflect.String)
+		return "", fmt.Errorf(cv.console.str().ErrVarBadType, cv.varName, reflect.String)
 	}
 	return value.(string), nil
 }
 
+// String returns the value of the convar as a string.
+//
+// Deprecated: Use StringVal instead. String will be turned into a fmt.Stringer in the next major version.
+// Until then, Format provides the readable form of a convar for the fmt package.
+func (cv *ConVar) String() (string, error) {
+	return cv.StringVal()
+}
+
 // Interface returns the value of the convar as an interface which is the underlying data type for all convars.
 // Interface will never return an error.
 func (cv *ConVar) Interface() (interface{}, error) {
-	return cv.value.Load(), nil
+	return cv.load(), nil
+}
+
+// MustBool is like Bool but panics if the convar is not of type int.
+// It should only be used when the type of the convar is statically known.
+func (cv *ConVar) MustBool() bool {
+	value, err := cv.Bool()
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+// MustInt is like Int but panics if the convar is not of type int.
+// It should only be used when the type of the convar is statically known.
+func (cv *ConVar) MustInt() int {
+	value, err := cv.Int()
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+// MustFloat64 is like Float64 but panics if the convar is not of type float64.
+// It should only be used when the type of the convar is statically known.
+func (cv *ConVar) MustFloat64() float64 {
+	value, err := cv.Float64()
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+// MustString is like StringVal but panics if the convar is not of type string.
+// It should only be used when the type of the convar is statically known.
+func (cv *ConVar) MustString() string {
+	value, err := cv.StringVal()
+	if err != nil {
+		panic(err)
+	}
+	return value
 }
 
 // SetBool sets the value of an integer convar from a boolean. true means 1 and false means 0.
+// See ConVar for the ordering of the callbacks of concurrent setters.
 func (cv *ConVar) SetBool(value bool) error {
 	if value {
-		return cv.write(reflect.Int, 1, 2)
+		_, err := cv.apply(context.Background(), reflect.Int, 1)
+		return err
 	}
-	return cv.write(reflect.Int, 0, 2)
+	_, err := cv.apply(context.Background(), reflect.Int, 0)
+	return err
 }
 
 // SetInt sets the convar to the given int value.
+// See ConVar for the ordering of the callbacks of concurrent setters.
 func (cv *ConVar) SetInt(value int) error {
-	return cv.write(reflect.Int, value, 2)
+	_, err := cv.apply(context.Background(), reflect.Int, value)
+	return err
 }
 
 // SetFloat64 sets the convar to the given float64 value.
+// See ConVar for the ordering of the callbacks of concurrent setters.
 func (cv *ConVar) SetFloat64(value float64) error {
-	return cv.write(reflect.Float64, value, 2)
+	_, err := cv.apply(context.Background(), reflect.Float64, value)
+	return err
 }
 
 // SetString sets the convar to the given string value.
+// See ConVar for the ordering of the callbacks of concurrent setters.
 func (cv *ConVar) SetString(value string) error {
-	return cv.write(reflect.String, value, 2)
+	_, err := cv.apply(context.Background(), reflect.String, value)
+	return err
+}
+
+// ForceSet sets the convar to the given value like the typed setters, but triggers its callback even if the value
+// is not changed, for ex. to apply the resolution again after the window is restored. This holds for debounced
+// and suppressed callbacks too, see SetCallbackDebounce and Console.SuppressCallbacks. The type of the value
+// must match the type of the convar.
+func (cv *ConVar) ForceSet(value interface{}) error {
+	varType := cv.varType
+	if value != nil {
+		varType = reflect.TypeOf(value).Kind()
+	}
+	_, err := cv.applyValue(context.Background(), varType, value, true)
+	return err
+}
+
+// DisplayValue returns the value of the convar formatted for display, for ex. in a console UI.
+// Floats are printed without exponent and trailing zeros, and strings are printed as they are, unquoted.
+// Int convars marked with AsBool are printed as true or false if their value is 0 or 1, like Bool, and as numbers
+// otherwise. Use Console.SetFormatter to control how values are written to config files instead.
+func (cv *ConVar) DisplayValue() string {
+	switch value := cv.get().(type) {
+	case int:
+		if cv.isBool() && (value == 0 || value == 1) {
+			return strconv.FormatBool(value == 1)
+		}
+		return strconv.Itoa(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case string:
+		return value
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// AsCommand returns the convar as a command that sets its current value, for ex. cl_width 800, which can be
+// copied into a config file or executed as it is. The value is formatted like in the files written by Save,
+// so string values starting with $ are escaped as \$, and empty string values and the ones containing whitespace
+// are quoted.
+func (cv *ConVar) AsCommand() string {
+	value := cv.format(cv.get())
+	escaped := strings.HasPrefix(value, "\"") || strings.HasPrefix(value, `\$`)
+	if cv.varType == reflect.String && !escaped && (value == "" || strings.IndexFunc(value, unicode.IsSpace) >= 0) {
+		// An empty value would turn the command into a query
+		value = quote(value)
+	}
+	return cv.varName + " " + value
+}
+
+// format formats the given value of the convar with the formatter of its console for the type of the convar.
+func (cv *ConVar) format(value interface{}) string {
+	if i, ok := value.(int); ok && cv.isBool() && (i == 0 || i == 1) {
+		// Other values are kept as numbers so that they load back unchanged
+		return strconv.FormatBool(i == 1)
+	}
+	return cv.console.formatter(cv.varType)(value)
 }
 
 // Name returns the name of the convar.
@@ -166,6 +566,16 @@ func (cv *ConVar) Name() string {
 	return cv.varName
 }
 
+// DisplayName returns the name of the convar with the casing it's created with, for ex. cl_Width, to be shown
+// in a UI. Lookups are still case-insensitive unless the console is case-sensitive, see Console.SetCaseSensitive.
+// It's the same as Name if the name is given in lowercase.
+func (cv *ConVar) DisplayName() string {
+	if cv.rawName == "" {
+		return cv.varName
+	}
+	return cv.rawName
+}
+
 // Desc returns the description of the convar.
 func (cv *ConVar) Desc() string {
 	return cv.varDesc
@@ -176,13 +586,311 @@ func (cv *ConVar) Type() reflect.Kind {
 	return cv.varType
 }
 
+// TypeName returns a human-readable label for the type of the convar, suitable for settings UIs.
+// Falls back to the name of the underlying reflect.Kind if no label is defined.
+func (cv *ConVar) TypeName() string {
+	if cv.isBool() {
+		return "Boolean"
+	}
+	if name, ok := typeNames[cv.varType]; ok {
+		return name
+	}
+	return cv.varType.String()
+}
+
+// AsBool marks an int convar as a boolean one, for display purposes only. The value is still stored as an int,
+// but Save and DisplayValue write 0 and 1 as false and true and other values as numbers, TypeName returns
+// "Boolean", and executed commands accept true and false besides the numbers. It returns the convar for chaining, for ex.
+// NewConVar("cl_vsync", reflect.Int, false, "Vertical sync.", 1, nil).AsBool()
+// AsBool panics if the convar is not an int convar.
+func (cv *ConVar) AsBool() *ConVar {
+	if cv.varType != reflect.Int {
+		panic(fmt.Errorf(errVarBadType, cv.varName, reflect.Int))
+	}
+	atomic.StoreInt32(&cv.asBool, 1)
+	return cv
+}
+
+// Greedy marks a string func convar as greedy, so that its callback receives the remainder of the command line
+// after the name as a single value, verbatim, for ex. say hello "world" receives hello "world". The remainder
+// keeps its case, its quotes and its spacing, and neither convar references nor environment variables are expanded.
+// Other string func convars, like if, receive the remainder as it is after the command is lowercased and
+// unquoted, and split it into tokens themselves. It returns the convar for chaining, like AsBool.
+// Greedy panics if the convar is not a string func convar.
+func (cv *ConVar) Greedy() *ConVar {
+	if !cv.isFunc || cv.varType != reflect.String {
+		panic(fmt.Errorf(errNotGreedy, cv.varName))
+	}
+	atomic.StoreInt32(&cv.greedy, 1)
+	return cv
+}
+
+// isGreedy reports whether the convar is marked with Greedy.
+func (cv *ConVar) isGreedy() bool {
+	return atomic.LoadInt32(&cv.greedy) == 1
+}
+
+// WriteOnly marks the convar as write-only, for ex. a password. Its value can still be set and read from Go and is
+// saved to config files as usual, but querying it from the console, referring to it via $name, using it in an if
+// command and reading it via HandleJSON result in an error, and its value isn't echoed. It returns the convar
+// for chaining, like AsBool.
+func (cv *ConVar) WriteOnly() *ConVar {
+	atomic.StoreInt32(&cv.writeOnly, 1)
+	return cv
+}
+
+// isWriteOnly reports whether the convar is marked with WriteOnly.
+func (cv *ConVar) isWriteOnly() bool {
+	return atomic.LoadInt32(&cv.writeOnly) == 1
+}
+
+// RegOrder returns the sequence number of the registration of the convar to its console, starting from 1.
+// Registering the convar again gives it a new number. It returns 0 if the convar is not registered.
+func (cv *ConVar) RegOrder() int {
+	return int(atomic.LoadInt32(&cv.regOrder))
+}
+
+// SetHidden hides or shows the convar in ConVarsSorted, the var_list command and the suggestions, for ex. for debug
+// convars that would clutter them for players. Hidden convars can still be executed, set and saved, and they
+// are listed and suggested again while the showhidden convar registered by RegDefaultConVars is set.
+func (cv *ConVar) SetHidden(hidden bool) {
+	if hidden {
+		atomic.StoreInt32(&cv.hidden, 1)
+	} else {
+		atomic.StoreInt32(&cv.hidden, 0)
+	}
+}
+
+// IsHidden reports whether the convar is hidden. See SetHidden.
+func (cv *ConVar) IsHidden() bool {
+	return atomic.LoadInt32(&cv.hidden) == 1
+}
+
+// isBool reports whether the convar is marked with AsBool.
+func (cv *ConVar) isBool() bool {
+	return atomic.LoadInt32(&cv.asBool) == 1
+}
+
+// SetRange limits the values of a numeric convar to the inclusive range [min, max].
+// Values outside of the range are rejected with an error. The bounds must be of the same type as the convar.
+func (cv *ConVar) SetRange(min, max interface{}) error {
+	if cv.varType != reflect.Int && cv.varType != reflect.Float64 {
+		return fmt.Errorf(cv.console.str().ErrRangeUnsupported, cv.varName)
+	}
+	for _, bound := range []interface{}{min, max} {
+		if bound == nil {
+			return errors.New(cv.console.str().ErrNilValue)
+		}
+		if reflect.TypeOf(bound).Kind() != cv.varType {
+			return fmt.Errorf(cv.console.str().ErrTypeMismatch, bound, cv.varName, cv.varType)
+		}
+	}
+	if compare(min, max) > 0 {
+		return fmt.Errorf(cv.console.str().ErrBadRange, min, max)
+	}
+	cv.metaLock.Lock()
+	defer cv.metaLock.Unlock()
+	cv.valMin, cv.valMax = min, max
+	return nil
+}
+
+// Range returns the bounds set by SetRange. ok is false if the convar is unbounded.
+func (cv *ConVar) Range() (min, max interface{}, ok bool) {
+	cv.metaLock.RLock()
+	defer cv.metaLock.RUnlock()
+	return cv.valMin, cv.valMax, cv.valMin != nil
+}
+
+// SetStep sets the increment of a numeric convar. The step is only informative, for ex. for settings UIs.
+// The step must be of the same type as the convar.
+func (cv *ConVar) SetStep(step interface{}) error {
+	if cv.varType != reflect.Int && cv.varType != reflect.Float64 {
+		return fmt.Errorf(cv.console.str().ErrRangeUnsupported, cv.varName)
+	}
+	if step == nil {
+		return errors.New(cv.console.str().ErrNilValue)
+	}
+	if reflect.TypeOf(step).Kind() != cv.varType {
+		return fmt.Errorf(cv.console.str().ErrTypeMismatch, step, cv.varName, cv.varType)
+	}
+	cv.metaLock.Lock()
+	defer cv.metaLock.Unlock()
+	cv.valStep = step
+	return nil
+}
+
+// Step returns the increment set by SetStep. ok is false if no step is set.
+func (cv *ConVar) Step() (step interface{}, ok bool) {
+	cv.metaLock.RLock()
+	defer cv.metaLock.RUnlock()
+	return cv.valStep, cv.valStep != nil
+}
+
+func (cv *ConVar) checkRange(value interface{}) error {
+	min, max, ok := cv.Range()
+	if !ok {
+		return nil
+	}
+	if compare(value, min) < 0 || compare(value, max) > 0 {
+		return fmt.Errorf(cv.console.str().ErrOutOfRange, value, cv.varName, min, max)
+	}
+	return nil
+}
+
+// compare compares two numeric values of the same type and returns -1, 0 or 1.
+func compare(a, b interface{}) int {
+	switch a := a.(type) {
+	case int:
+		b := b.(int)
+		if a < b {
+			return -1
+		} else if a > b {
+			return 1
+		}
+	case float64:
+		b := b.(float64)
+		if a < b {
+			return -1
+		} else if a > b {
+			return 1
+		}
+	}
+	return 0
+}
+
+// SetUsage sets the call syntax of the convar, for ex. "bind <key> <command>", which is printed by the help command
+// together with the description. It's mainly meant for func convars and it's empty by default.
+func (cv *ConVar) SetUsage(usage string) {
+	cv.metaLock.Lock()
+	defer cv.metaLock.Unlock()
+	cv.usage = usage
+}
+
+// Usage returns the call syntax set by SetUsage.
+func (cv *ConVar) Usage() string {
+	cv.metaLock.RLock()
+	defer cv.metaLock.RUnlock()
+	return cv.usage
+}
+
+// SetCategory sets the category of the convar, for ex. "Graphics". Save groups the convars into sections by category.
+func (cv *ConVar) SetCategory(category string) {
+	cv.metaLock.Lock()
+	defer cv.metaLock.Unlock()
+	cv.category = category
+}
+
+// Category returns the category set by SetCategory, which is empty if no category is set.
+func (cv *ConVar) Category() string {
+	cv.metaLock.RLock()
+	defer cv.metaLock.RUnlock()
+	return cv.category
+}
+
+// SetPrivilege sets the privilege level required to execute the convar via Console.ExecCmdPriv.
+// The default level is 0, which means everyone is allowed.
+func (cv *ConVar) SetPrivilege(level int) {
+	atomic.StoreInt32(&cv.privilege, int32(level))
+}
+
+// Privilege returns the privilege level required to execute the convar. See SetPrivilege.
+func (cv *ConVar) Privilege() int {
+	return int(atomic.LoadInt32(&cv.privilege))
+}
+
+// SetCallbackDebounce delays the callback of the convar until its value hasn't changed for the given duration.
+// The value itself is still updated immediately, only the callback is coalesced and triggered at most once
+// per settled change. It is triggered from a separate goroutine. Zero, the default, disables debouncing.
+// Use Console.FlushCallbacks to trigger the pending callbacks immediately, for ex. on shutdown.
+// Func convars are not affected.
+func (cv *ConVar) SetCallbackDebounce(d time.Duration) {
+	cv.cbLock.Lock()
+	defer cv.cbLock.Unlock()
+	cv.debounce = d
+}
+
+// clone returns an unregistered copy of the convar with an independent value.
+func (cv *ConVar) clone() *ConVar {
+	cv.metaLock.RLock()
+	defer cv.metaLock.RUnlock()
+	cp := &ConVar{
+		varName:    cv.varName,
+		rawName:    cv.rawName,
+		varType:    cv.varType,
+		varDesc:    cv.varDesc,
+		valDefault: cv.valDefault,
+		valSet:     cv.valSet,
+		valSetCtx:  cv.valSetCtx,
+		getter:     cv.getter,
+		isFunc:     cv.isFunc,
+		fileSafe:   cv.fileSafe,
+		needsArg:   cv.needsArg,
+		useDefault: cv.useDefault,
+		greedy:     atomic.LoadInt32(&cv.greedy),
+		writeOnly:  atomic.LoadInt32(&cv.writeOnly),
+		privilege:  atomic.LoadInt32(&cv.privilege),
+		asBool:     atomic.LoadInt32(&cv.asBool),
+		hidden:     atomic.LoadInt32(&cv.hidden),
+		regOrder:   atomic.LoadInt32(&cv.regOrder),
+		valMin:     cv.valMin,
+		valMax:     cv.valMax,
+		valStep:    cv.valStep,
+		category:   cv.category,
+		usage:      cv.usage,
+		options:    cv.options,
+		debounce:   cv.debounceDuration(),
+	}
+	cp.value.Store(cv.value.Load())
+	return cp
+}
+
+func (cv *ConVar) debounceDuration() time.Duration {
+	cv.cbLock.Lock()
+	defer cv.cbLock.Unlock()
+	return cv.debounce
+}
+
 // Reset resets a convar to its default value.
 // Value set/update callback function is not triggered.
 func (cv *ConVar) Reset() {
+	unlock := cv.lock()
+	changed := cv.value.Load() != cv.valDefault
 	cv.value.Store(cv.valDefault)
+	unlock()
+	if changed {
+		cv.console.recordChange(cv)
+	}
+}
+
+// lock serializes the value mutations of the convar and keeps them from being interleaved with a ReadGroup
+// of its console. The console lock is acquired first to keep the lock order of ResetAllVar.
+func (cv *ConVar) lock() (unlock func()) {
+	con := cv.console
+	if con != nil {
+		con.valLock.RLock()
+	}
+	cv.setLock.Lock()
+	return func() {
+		cv.setLock.Unlock()
+		if con != nil {
+			con.valLock.RUnlock()
+		}
+	}
 }
 
 // IsFunc returns true if the convar is set as a function.
 func (cv *ConVar) IsFunc() bool {
 	return cv.isFunc
 }
+
+// Format implements fmt.Formatter so that a convar prints in a readable form
+// like "cl_width=800 (int, default 640)" with the %v and %s verbs.
+// String is not used for this purpose since it is still the deprecated value accessor of the convar.
+func (cv *ConVar) Format(f fmt.State, verb rune) {
+	switch verb {
+	case 'v', 's':
+		fmt.Fprintf(f, "%s=%v (%s, default %v)", cv.varName, cv.get(), cv.varType, cv.valDefault)
+	default:
+		fmt.Fprintf(f, "%%!%c(*convar.ConVar=%s)", verb, cv.varName)
+	}
+}
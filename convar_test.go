@@ -0,0 +1,358 @@
+// Copyright © 2020 Cosku Bas. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package convar
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentSetReset(t *testing.T) {
+	const (
+		goroutines = 8
+		iterations = 200
+	)
+	var (
+		lock  sync.Mutex
+		pairs [][2]int
+	)
+	c := NewConsole(10, LogError, "", "", "")
+	cv := NewConVar("cl_width", reflect.Int, false, "", 0, func(con *Console, oldVal, newVal interface{}) {
+		lock.Lock()
+		pairs = append(pairs, [2]int{oldVal.(int), newVal.(int)})
+		lock.Unlock()
+	})
+	c.RegConVar(cv)
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				if i%3 == 0 {
+					cv.Reset()
+				} else if err := cv.SetInt(g*iterations + i); err != nil {
+					t.Error(err)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	max := goroutines * iterations
+	if len(pairs) == 0 {
+		t.Fatal("no callback is triggered")
+	}
+	for _, p := range pairs {
+		if p[0] == p[1] {
+			t.Errorf("callback is triggered without a change: %d -> %d", p[0], p[1])
+		}
+		if p[0] < 0 || p[0] >= max || p[1] < 0 || p[1] >= max {
+			t.Errorf("callback received a value that is never set: %d -> %d", p[0], p[1])
+		}
+	}
+	if got := cv.MustInt(); got < 0 || got >= max {
+		t.Errorf("cl_width = %d, which is never set", got)
+	}
+}
+
+func TestStringValAndFormat(t *testing.T) {
+	name := NewConVar("name", reflect.String, false, "", "player", nil)
+	width := NewConVar("cl_width", reflect.Int, false, "", 640, nil)
+	width.SetInt(800)
+
+	if got, err := name.StringVal(); got != "player" || err != nil {
+		t.Errorf("StringVal = %q, %v, want player", got, err)
+	}
+	if got, err := name.String(); got != "player" || err != nil {
+		t.Errorf("String = %q, %v, want player", got, err)
+	}
+	if _, err := width.StringVal(); err == nil {
+		t.Error("StringVal of an int convar doesn't fail")
+	}
+	for format, want := range map[string]string{
+		"%v": "cl_width=800 (int, default 640)",
+		"%s": "cl_width=800 (int, default 640)",
+		"%d": "%!d(*convar.ConVar=cl_width)",
+	} {
+		if got := fmt.Sprintf(format, width); got != want {
+			t.Errorf("Sprintf(%q) = %q, want %q", format, got, want)
+		}
+	}
+}
+
+// recorder records the values the callback of a convar receives.
+type recorder struct {
+	calls [][2]interface{}
+}
+
+func (r *recorder) fn(con *Console, oldVal, newVal interface{}) {
+	r.calls = append(r.calls, [2]interface{}{oldVal, newVal})
+}
+
+func TestSetBool(t *testing.T) {
+	c := NewConsole(10, LogError, "", "", "")
+	var r recorder
+	cv := NewConVar("r_vsync", reflect.Int, false, "", 0, r.fn)
+	c.RegConVar(cv)
+
+	for _, value := range []bool{true, true, false, false, true} {
+		if err := cv.SetBool(value); err != nil {
+			t.Fatal(err)
+		}
+		if got := cv.MustBool(); got != value {
+			t.Errorf("SetBool(%v): value = %v", value, got)
+		}
+	}
+	want := [][2]interface{}{{0, 1}, {1, 0}, {0, 1}}
+	if !reflect.DeepEqual(r.calls, want) {
+		t.Errorf("callback received %v, want %v", r.calls, want)
+	}
+
+	// A func convar only receives the value, it isn't stored
+	var rf recorder
+	fn := NewConVar("r_restart", reflect.Int, true, "", 0, rf.fn)
+	c.RegConVar(fn)
+	fn.SetBool(true)
+	fn.SetBool(true)
+	want = [][2]interface{}{{0, 1}, {0, 1}}
+	if !reflect.DeepEqual(rf.calls, want) {
+		t.Errorf("func callback received %v, want %v", rf.calls, want)
+	}
+	if got := fn.MustInt(); got != 0 {
+		t.Errorf("func value = %d, want 0", got)
+	}
+
+	str := NewConVar("name", reflect.String, false, "", "", nil)
+	c.RegConVar(str)
+	if err := str.SetBool(true); err == nil {
+		t.Error("SetBool of a string convar doesn't fail")
+	}
+}
+
+func TestExecSetInvokeQuery(t *testing.T) {
+	c := NewConsole(10, LogError, "", "", "")
+	var rv, rf recorder
+	width := NewConVar("cl_width", reflect.Int, false, "", 640, rv.fn)
+	reload := NewConVar("cl_reload", reflect.Int, true, "", 0, rf.fn)
+	c.RegConVar(width)
+	c.RegConVar(reload)
+
+	// Setting a var stores the value and triggers the callback only if it's changed
+	for _, cmd := range []string{"cl_width 800", "cl_width 800", "cl_width"} {
+		if _, err := c.ExecCmd(cmd); err != nil {
+			t.Fatalf("%q: %v", cmd, err)
+		}
+	}
+	if got := width.MustInt(); got != 800 {
+		t.Errorf("cl_width = %d, want 800", got)
+	}
+	if want := [][2]interface{}{{640, 800}}; !reflect.DeepEqual(rv.calls, want) {
+		t.Errorf("cl_width callback received %v, want %v", rv.calls, want)
+	}
+
+	// Invoking a func triggers the callback every time without storing the value
+	for _, cmd := range []string{"cl_reload 10", "cl_reload 10"} {
+		if _, err := c.ExecCmd(cmd); err != nil {
+			t.Fatalf("%q: %v", cmd, err)
+		}
+	}
+	if got := reload.MustInt(); got != 0 {
+		t.Errorf("cl_reload = %d, want 0", got)
+	}
+	if want := [][2]interface{}{{0, 10}, {0, 10}}; !reflect.DeepEqual(rf.calls, want) {
+		t.Errorf("cl_reload callback received %v, want %v", rf.calls, want)
+	}
+
+	// The setters follow the same rules
+	width.SetInt(800)
+	reload.SetInt(10)
+	if len(rv.calls) != 1 || len(rf.calls) != 3 {
+		t.Errorf("setters triggered %d var and %d func callbacks, want 1 and 3", len(rv.calls), len(rf.calls))
+	}
+}
+
+func TestNilCallback(t *testing.T) {
+	c := NewConsole(10, LogError, "", "", "")
+	width := NewConVar("cl_width", reflect.Int, false, "", 640, nil)
+	reload := NewConVar("cl_reload", reflect.Int, true, "", 0, nil)
+	c.RegConVar(width)
+	c.RegConVar(reload)
+
+	if err := width.SetInt(800); err != nil {
+		t.Fatal(err)
+	}
+	if err := width.ForceSet(800); err != nil {
+		t.Fatal(err)
+	}
+	if err := reload.SetInt(1); err != nil {
+		t.Fatal(err)
+	}
+	for _, cmd := range []string{"cl_width 1024", "cl_reload", "cl_reload 2"} {
+		if _, err := c.ExecCmd(cmd); err != nil {
+			t.Fatalf("%q: %v", cmd, err)
+		}
+	}
+	width.Reset()
+	if got := width.MustInt(); got != 640 {
+		t.Errorf("cl_width = %d, want 640", got)
+	}
+}
+
+func TestNotFinite(t *testing.T) {
+	c := NewConsole(10, LogError, "", "", "")
+	var r recorder
+	cv := NewConVar("sensitivity", reflect.Float64, false, "", 1.5, r.fn)
+	c.RegConVar(cv)
+
+	for _, value := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if err := cv.SetFloat64(value); err == nil {
+			t.Errorf("SetFloat64(%v) doesn't fail", value)
+		}
+	}
+	for _, cmd := range []string{"sensitivity nan", "sensitivity NaN", "sensitivity inf", "sensitivity -Inf", "sensitivity 1e999"} {
+		if _, err := c.ExecCmd(cmd); err == nil {
+			t.Errorf("%q doesn't fail", cmd)
+		}
+	}
+	if got := cv.MustFloat64(); got != 1.5 {
+		t.Errorf("sensitivity = %v, want 1.5", got)
+	}
+	if len(r.calls) != 0 {
+		t.Errorf("callback received %v, want nothing", r.calls)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("NewConVar with a NaN default doesn't panic")
+		}
+	}()
+	NewConVar("bad", reflect.Float64, false, "", math.NaN(), nil)
+}
+
+func TestNilDefault(t *testing.T) {
+	for _, fn := range []bool{false, true} {
+		func() {
+			defer func() {
+				r := recover()
+				err, ok := r.(error)
+				if !ok || err.Error() != "default value for convar cl_width cannot be nil" {
+					t.Errorf("func %v: NewConVar with a nil default panics with %v", fn, r)
+				}
+			}()
+			NewConVar("cl_width", reflect.Int, fn, "", nil, nil)
+		}()
+	}
+}
+
+func TestBareFuncValue(t *testing.T) {
+	c := NewConsole(10, LogError, "", "", "")
+	var plainInt, plainStr, fn, fnCtx recorder
+	c.RegConVar(NewConVar("cl_reload", reflect.Int, true, "", 5, plainInt.fn))
+	c.RegConVar(NewConVar("map", reflect.String, true, "", "de_dust", plainStr.fn))
+	c.RegConVar(NewFunc("kick", reflect.Int, false, "", 7, fn.fn))
+	c.RegConVar(NewFuncContext("connect", reflect.String, false, "", "localhost", func(ctx context.Context, con *Console, oldVal, newVal interface{}) {
+		fnCtx.fn(con, oldVal, newVal)
+	}))
+	var cmds int
+	c.RegConVar(NewCmd("quit", "", func(con *Console) { cmds++ }))
+
+	for _, cmd := range []string{"cl_reload", "map", "kick", "connect", "quit", "quit 1"} {
+		if _, err := c.ExecCmd(cmd); err != nil {
+			t.Fatalf("%q: %v", cmd, err)
+		}
+	}
+	// Plain func convars keep receiving 0 and an empty string, the others receive the default
+	for name, r := range map[string]struct {
+		got  *recorder
+		want [2]interface{}
+	}{
+		"cl_reload": {&plainInt, [2]interface{}{5, 0}},
+		"map":       {&plainStr, [2]interface{}{"de_dust", ""}},
+		"kick":      {&fn, [2]interface{}{7, 7}},
+		"connect":   {&fnCtx, [2]interface{}{"localhost", "localhost"}},
+	} {
+		if want := [][2]interface{}{r.want}; !reflect.DeepEqual(r.got.calls, want) {
+			t.Errorf("%s callback received %v, want %v", name, r.got.calls, want)
+		}
+	}
+	if cmds != 2 {
+		t.Errorf("quit is called %d times, want 2", cmds)
+	}
+}
+
+func TestForceSetDebounced(t *testing.T) {
+	c := NewConsole(10, LogError, "", "", "")
+	calls := make(chan [2]interface{}, 10)
+	cv := NewConVar("r_mode", reflect.Int, false, "", 1, func(con *Console, oldVal, newVal interface{}) {
+		calls <- [2]interface{}{oldVal, newVal}
+	})
+	c.RegConVar(cv)
+	cv.SetCallbackDebounce(10 * time.Millisecond)
+
+	if err := cv.ForceSet(1); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-calls:
+		if want := [2]interface{}{1, 1}; got != want {
+			t.Errorf("callback received %v, want %v", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("debounced callback of ForceSet isn't triggered")
+	}
+
+	// A forced set followed by a change back keeps the pending callback forced
+	cv.ForceSet(2)
+	cv.SetInt(1)
+	if got := <-calls; got != [2]interface{}{1, 1} {
+		t.Errorf("callback received %v, want [1 1]", got)
+	}
+
+	// Plain sets without a net change are still dropped
+	cv.SetInt(2)
+	cv.SetInt(1)
+	select {
+	case got := <-calls:
+		t.Errorf("callback received %v without a change", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestForceSetSuppressed(t *testing.T) {
+	c := NewConsole(10, LogError, "", "", "")
+	var r, other recorder
+	cv := NewConVar("r_mode", reflect.Int, false, "", 1, r.fn)
+	unchanged := NewConVar("r_fov", reflect.Int, false, "", 90, other.fn)
+	c.RegConVar(cv)
+	c.RegConVar(unchanged)
+
+	c.SuppressCallbacks(func() {
+		cv.ForceSet(1)
+		unchanged.SetInt(100)
+		unchanged.SetInt(90)
+	})
+	if want := [][2]interface{}{{1, 1}}; !reflect.DeepEqual(r.calls, want) {
+		t.Errorf("r_mode callback received %v, want %v", r.calls, want)
+	}
+	if len(other.calls) != 0 {
+		t.Errorf("r_fov callback received %v without a change", other.calls)
+	}
+
+	// The force flag doesn't outlive the suppression
+	c.SuppressCallbacks(func() {
+		cv.SetInt(2)
+		cv.SetInt(1)
+	})
+	if len(r.calls) != 1 {
+		t.Errorf("r_mode callback received %v after an unforced suppression", r.calls)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright © 2020 Cosku Bas. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package convar
+
+import (
+	"context"
+)
+
+// The callbacks of the convars registered by RegDefaultConVars. They can be used to compose custom convars,
+// for ex. one that calls DefaultSaveFunc and then uploads the saved file.
+// The ones that take a file or a name fall back to the previous value of the convar when none is given.
+
+// argOrPrev returns the argument given to a string command, or the previous value if none is given.
+func argOrPrev(oldVal, newVal interface{}) string {
+	if arg := newVal.(string); arg != "" {
+		return arg
+	}
+	return oldVal.(string)
+}
+
+// DefaultDumpFunc is the callback of con_dump, which saves the console buffer to a file.
+func DefaultDumpFunc(con *Console, oldVal, newVal interface{}) {
+	file := argOrPrev(oldVal, newVal)
+	if err := con.DumpBuffer(file); err != nil {
+		con.LogErrorf("%v", err)
+		return
+	}
+	con.LogInfof(con.str().MsgSaved, file)
+}
+
+// DefaultClearFunc is the callback of con_clear, which clears the console buffer.
+func DefaultClearFunc(con *Console, oldVal, newVal interface{}) {
+	con.ClearBuffer()
+}
+
+// DefaultResetAllFunc is the callback of var_reset_all, which resets all convars to their default values.
+// When executed via Console.ExecCmdPriv, the convars that require a higher privilege are left untouched.
+func DefaultResetAllFunc(ctx context.Context, con *Console, oldVal, newVal interface{}) {
+	con.resetAll(stateFrom(ctx))
+}
+
+// DefaultResetFunc is the callback of var_reset, which resets the named convar to its default value.
+// When executed via Console.ExecCmdPriv, a convar that requires a higher privilege is not reset.
+func DefaultResetFunc(ctx context.Context, con *Console, oldVal, newVal interface{}) {
+	cv := con.ConVar(newVal.(string))
+	if cv == nil {
+		con.LogErrorf(con.str().ErrVarNotFound, newVal.(string))
+		return
+	}
+	if !stateFrom(ctx).allows(cv) {
+		con.LogErrorf(con.str().ErrNoPrivilege, cv.varName)
+		return
+	}
+	cv.Reset()
+	con.LogInfof(con.str().MsgReset, newVal.(string))
+}
+
+// DefaultLoadFunc is the callback of var_load, which loads convars from a file.
+// When executed via Console.ExecCmdPriv, the lines of the file are executed with the same privilege level.
+func DefaultLoadFunc(ctx context.Context, con *Console, oldVal, newVal interface{}) {
+	file := argOrPrev(oldVal, newVal)
+	if err := con.load(stateFrom(ctx), file); err != nil {
+		con.LogErrorf("%v", err)
+		return
+	}
+	con.LogInfof(con.str().MsgLoaded, file)
+}
+
+// DefaultSaveFunc is the callback of var_save, which saves convars to a file.
+func DefaultSaveFunc(con *Console, oldVal, newVal interface{}) {
+	file := argOrPrev(oldVal, newVal)
+	if err := con.Save(file); err != nil {
+		con.LogErrorf("%v", err)
+		return
+	}
+	con.LogInfof(con.str().MsgSaved, file)
+}
+
+// DefaultListFunc is the callback of var_list, which lists all convars with their description, sorted by name.
+func DefaultListFunc(ctx context.Context, con *Console, oldVal, newVal interface{}) {
+	cvs := con.ConVarsSorted()
+	for _, cv := range cvs {
+		con.Outputf(ctx, "%s (%s): %s", cv.varName, cv.TypeName(), cv.varDesc)
+	}
+}
+
+// DefaultHelpFunc is the callback of help, which prints the description and the usage of the named convar.
+func DefaultHelpFunc(ctx context.Context, con *Console, oldVal, newVal interface{}) {
+	name := argOrPrev(oldVal, newVal)
+	cv := con.ConVar(name)
+	if cv == nil {
+		con.LogErrorf(con.str().ErrVarNotFound, name)
+		return
+	}
+	con.Outputf(ctx, "%s (%s): %s", cv.varName, cv.TypeName(), cv.varDesc)
+	if usage := cv.Usage(); usage != "" {
+		con.Outputf(ctx, con.str().MsgUsage, usage)
+	}
+}
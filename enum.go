@@ -0,0 +1,62 @@
+// Copyright © 2020 Cosku Bas. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package convar
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// NewEnumConVar returns a string convar that only accepts the given options, for ex. "low", "medium" and "high".
+// Values are matched case insensitively and stored with the casing of the matching option, so `r_quality HIGH`
+// sets the value to "high". Setting any other value results in an error that lists the options.
+// NewEnumConVar panics if there are no options or valDefault is not one of them.
+func NewEnumConVar(varName string, varDesc string, options []string, valDefault string, valSet ValSetFunc) *ConVar {
+	cv := NewConVar(varName, reflect.String, false, varDesc, valDefault, valSet)
+	if len(options) == 0 {
+		// A nil options slice would accept any value
+		panic(fmt.Errorf(errNoOptions, cv.varName))
+	}
+	cv.options = append([]string(nil), options...)
+	if cv.canonical(valDefault) != valDefault || cv.checkOption(valDefault) != nil {
+		panic(fmt.Errorf(errBadEnumDefault, valDefault, cv.varName))
+	}
+	return cv
+}
+
+// Options returns a copy of the options of an enum convar, nil for other convars. See NewEnumConVar.
+func (cv *ConVar) Options() []string {
+	if cv.options == nil {
+		return nil
+	}
+	return append([]string(nil), cv.options...)
+}
+
+// canonical returns the option of an enum convar that matches value case insensitively,
+// or value as it is if there is no such option or the convar is not an enum.
+func (cv *ConVar) canonical(value interface{}) interface{} {
+	if s, ok := value.(string); ok {
+		for _, option := range cv.options {
+			if strings.EqualFold(option, s) {
+				return option
+			}
+		}
+	}
+	return value
+}
+
+// checkOption checks whether value is one of the options of an enum convar.
+func (cv *ConVar) checkOption(value interface{}) error {
+	if cv.options == nil {
+		return nil
+	}
+	for _, option := range cv.options {
+		if value == option {
+			return nil
+		}
+	}
+	return fmt.Errorf(cv.console.str().ErrBadOption, value, cv.varName, strings.Join(cv.options, ", "))
+}
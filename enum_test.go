@@ -0,0 +1,60 @@
+// Copyright © 2020 Cosku Bas. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package convar
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnumCanonical(t *testing.T) {
+	c := NewConsole(100, LogError, "", "", "")
+	cv := NewEnumConVar("r_quality", "", []string{"Low", "medium", "ULTRA"}, "medium", nil)
+	c.RegConVar(cv)
+
+	for cmd, want := range map[string]string{
+		"R_Quality HIGH":   "",
+		"r_quality low":    "Low",
+		"R_QUALITY Ultra":  "ULTRA",
+		"r_quality MeDiUm": "medium",
+	} {
+		c.ResetAllVar()
+		_, err := c.ExecCmd(cmd)
+		if want == "" {
+			if err == nil || !strings.Contains(err.Error(), "Low, medium, ULTRA") {
+				t.Errorf("%q: error = %v, want one listing the options", cmd, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", cmd, err)
+		}
+		if got := cv.MustString(); got != want {
+			t.Errorf("%q: value = %q, want %q", cmd, got, want)
+		}
+	}
+
+	if err := cv.SetString("uLtRa"); err != nil || cv.MustString() != "ULTRA" {
+		t.Errorf("SetString(uLtRa) = %v, value %q, want ULTRA", err, cv.MustString())
+	}
+	if err := cv.SetString("extreme"); err == nil {
+		t.Error("SetString(extreme) doesn't fail")
+	}
+}
+
+func TestEnumNoOptions(t *testing.T) {
+	for _, options := range [][]string{nil, {}} {
+		func() {
+			defer func() {
+				r := recover()
+				err, ok := r.(error)
+				if !ok || err.Error() != "enum variable r_quality has no options" {
+					t.Errorf("options %#v: NewEnumConVar panics with %v", options, r)
+				}
+			}()
+			NewEnumConVar("r_quality", "", options, "", nil)
+		}()
+	}
+}
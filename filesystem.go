@@ -7,36 +7,380 @@ package convar
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
 )
 
-// Save saves all convars to the given config file. Only non-default values are saved.
+// DefaultCategory is the config file section of the convars without a category. See ConVar.SetCategory.
+const DefaultCategory = "General"
+
+// Save saves all convars to the given config file, sorted by name. Only non-default values are saved.
+// If any of the saved convars has a category, the convars are grouped into INI-like sections by category
+// instead, such as [Graphics], with the sections sorted by name. Convars without a category
+// are saved in the DefaultCategory section. Load skips the section headers.
 func (c *Console) Save(filePath string) error {
-	var buffer bytes.Buffer
+	var (
+		buffer      bytes.Buffer
+		categorized bool
+		sections    = make(map[string][]savedVar)
+	)
+	// The values are snapshotted under the lock, they are formatted and written after it's released
+	// since formatters and storages are user code
+	for _, sv := range c.savedVars() {
+		category := sv.cv.Category()
+		if category == "" {
+			category = DefaultCategory
+		} else {
+			categorized = true
+		}
+		sections[category] = append(sections[category], sv)
+	}
+	if !categorized {
+		// Without categories the file is kept as a plain list of commands
+		for _, sv := range sections[DefaultCategory] {
+			buffer.WriteString(c.configLine(sv))
+		}
+		return c.writeConfig(filePath, buffer.Bytes())
+	}
+
+	categories := make([]string, 0, len(sections))
+	for category := range sections {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for i, category := range categories {
+		if i > 0 {
+			buffer.WriteString("\n")
+		}
+		buffer.WriteString("[" + category + "]\n")
+		for _, sv := range sections[category] {
+			buffer.WriteString(c.configLine(sv))
+		}
+	}
+	return c.writeConfig(filePath, buffer.Bytes())
+}
+
+// savedVar is a convar with the value that is saved to a config file.
+type savedVar struct {
+	cv    *ConVar
+	value interface{}
+}
+
+// savedVars returns the convars saved by Save with their current values, sorted by name.
+func (c *Console) savedVars() []savedVar {
 	c.varLock.RLock()
 	defer c.varLock.RUnlock()
-	for _, cv := range c.variables {
-		value := cv.value.Load()
-		if value != cv.valDefault && !cv.isFunc {
-			buffer.WriteString(fmt.Sprintf("%s %v\n", cv.varName, value))
+	var saved []savedVar
+	for _, cv := range c.sortedLocked() {
+		if value := cv.value.Load(); c.savable(cv) && value != cv.valDefault {
+			saved = append(saved, savedVar{cv: cv, value: value})
+		}
+	}
+	return saved
+}
+
+// isSection reports whether a trimmed config file line is an INI-like section header, for ex. [Graphics].
+func isSection(line string) bool {
+	return strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]")
+}
+
+// SaveMerge updates the values in the given config file while preserving its structure.
+// Lines setting a registered convar are rewritten with its current value, keeping a trailing comment like in
+// cl_width 800 # tuned, and all other lines like comments, blank lines and unknown commands are left untouched.
+// Non-default convars that are not in the file yet are added to the end of the section of their category,
+// sorted by name, and the sections missing from the file are appended like Save writes them. In a file without
+// any sections, the convars without a category are simply appended at the end. If the file doesn't exist,
+// SaveMerge behaves like Save.
+func (c *Console) SaveMerge(filePath string) error {
+	data, err := c.files().ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return c.Save(filePath)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Each line is either kept as it is or, if cv is set, rewritten with the value and the comment
+	type mergeLine struct {
+		line    string
+		comment string
+		savedVar
+	}
+	var (
+		merged    []mergeLine
+		added     []savedVar
+		section   string
+		sectioned bool
+		// Index of the last non-blank line of each section, where the new convars are inserted
+		sectionEnd = make(map[string]int)
+	)
+	c.varLock.RLock()
+	seen := make(map[string]bool)
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if isSection(trimmed) {
+			section = strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+			sectioned = true
+		}
+		if trimmed != "" {
+			sectionEnd[section] = len(merged)
+		}
+		if tokens := strings.Fields(c.fold(c.configCmd(line))); len(tokens) > 0 {
+			if cv, ok := c.variables[tokens[0]]; ok && c.savable(cv) {
+				m := mergeLine{savedVar: savedVar{cv: cv, value: cv.value.Load()}}
+				// Only non-string values can be followed by a comment, see Console.ExecCmd
+				if cv.varType != reflect.String {
+					m.comment = inlineComment(line)
+				}
+				merged = append(merged, m)
+				seen[cv.varName] = true
+				continue
+			}
+		}
+		merged = append(merged, mergeLine{line: line})
+	}
+	for _, cv := range c.sortedLocked() {
+		if value := cv.value.Load(); !seen[cv.varName] && c.savable(cv) && value != cv.valDefault {
+			added = append(added, savedVar{cv: cv, value: value})
+		}
+	}
+	c.varLock.RUnlock()
+
+	// The lines before the first section belong to no section, so they only take the convars without a category
+	// if the file has no sections at all
+	var (
+		inserted = make(map[int][]savedVar)
+		appended []savedVar
+		sections = make(map[string][]savedVar)
+	)
+	for _, sv := range added {
+		category := sv.cv.Category()
+		if category == "" {
+			if !sectioned {
+				appended = append(appended, sv)
+				continue
+			}
+			category = DefaultCategory
+		}
+		if i, ok := sectionEnd[category]; ok {
+			inserted[i] = append(inserted[i], sv)
+		} else {
+			sections[category] = append(sections[category], sv)
 		}
 	}
-	return ioutil.WriteFile(filePath, buffer.Bytes(), os.ModePerm)
+
+	// Formatters and storages are user code, so they are called without holding the lock
+	var buffer bytes.Buffer
+	for i, m := range merged {
+		if m.cv != nil {
+			buffer.WriteString(strings.TrimSuffix(c.configLine(m.savedVar), "\n") + m.comment + "\n")
+		} else {
+			buffer.WriteString(m.line)
+			if !strings.HasSuffix(m.line, "\n") {
+				buffer.WriteString("\n")
+			}
+		}
+		for _, sv := range inserted[i] {
+			buffer.WriteString(c.configLine(sv))
+		}
+	}
+	for _, sv := range appended {
+		buffer.WriteString(c.configLine(sv))
+	}
+	categories := make([]string, 0, len(sections))
+	for category := range sections {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		if buffer.Len() > 0 {
+			buffer.WriteString("\n")
+		}
+		buffer.WriteString("[" + category + "]\n")
+		for _, sv := range sections[category] {
+			buffer.WriteString(c.configLine(sv))
+		}
+	}
+	return c.writeConfig(filePath, buffer.Bytes())
+}
+
+// inlineComment returns the comment at the end of a config file line with its leading whitespace,
+// for ex. " # tuned" for cl_width 800 # tuned, or an empty string if the line has none.
+func inlineComment(line string) string {
+	line = strings.TrimRight(line, "\r\n")
+	for i := 1; i < len(line); i++ {
+		if line[i] == '#' && (line[i-1] == ' ' || line[i-1] == '\t') {
+			j := i
+			for j > 0 && (line[j-1] == ' ' || line[j-1] == '\t') {
+				j--
+			}
+			return line[j:]
+		}
+	}
+	return ""
+}
+
+// writeConfig writes a config file and marks the console as clean if it succeeds, see IsDirty.
+func (c *Console) writeConfig(filePath string, data []byte) error {
+	if err := c.files().WriteFile(filePath, data, c.perm()); err != nil {
+		return err
+	}
+	c.MarkClean()
+	return nil
+}
+
+// IsDirty reports whether the value of any convar that is saved to config files is changed since the last
+// successful Save, SaveMerge or Load, or since the call to MarkClean, for ex. to ask to save the changes on exit.
+// Only actual changes count, setting a convar to its current value doesn't make the console dirty.
+func (c *Console) IsDirty() bool {
+	return atomic.LoadInt32(&c.dirty) == 1
+}
+
+// MarkClean clears the dirty state reported by IsDirty, for ex. after saving the convars in a custom way.
+func (c *Console) MarkClean() {
+	atomic.StoreInt32(&c.dirty, 0)
+}
+
+// sortedLocked returns the registered convars sorted by name. varLock must be held by the caller.
+func (c *Console) sortedLocked() []*ConVar {
+	cvs := make([]*ConVar, 0, len(c.variables))
+	for _, cv := range c.variables {
+		cvs = append(cvs, cv)
+	}
+	// Sorted for a stable, diff-friendly output
+	sortByName(cvs)
+	return cvs
+}
+
+// savable reports whether the convar is written to config files. varLock must be held by the caller.
+func (c *Console) savable(cv *ConVar) bool {
+	_, deprecated := c.deprecated[cv.varName]
+	return !cv.isFunc && cv.getter == nil && !deprecated
+}
+
+// configLine returns the config file line that sets the convar to the saved value.
+func (c *Console) configLine(sv savedVar) string {
+	return sv.cv.varName + c.separator() + sv.cv.format(sv.value) + "\n"
+}
+
+// SetConfigSeparator sets the separator between the name and the value of the convars in config files,
+// which is a space by default. For ex. with "=", Save writes INI-like lines such as cl_width=800.
+// Load and SaveMerge then split each line at the first separator, as long as it comes right after the name.
+// Lines without the separator, like commands taking multiple arguments, are executed as they are.
+// An empty separator restores the default.
+func (c *Console) SetConfigSeparator(sep string) {
+	if sep == "" {
+		sep = " "
+	}
+	c.configSep.Store(sep)
+}
+
+// separator returns the separator between the name and the value of the convars in config files.
+func (c *Console) separator() string {
+	if sep, ok := c.configSep.Load().(string); ok {
+		return sep
+	}
+	return " "
+}
+
+// configCmd converts a config file line that uses the config separator to a command.
+func (c *Console) configCmd(line string) string {
+	sep := c.separator()
+	if strings.TrimSpace(sep) == "" {
+		return line
+	}
+	i := strings.Index(line, sep)
+	if i < 0 {
+		return line
+	}
+	name := strings.TrimSpace(line[:i])
+	if name == "" || strings.ContainsAny(name, " \t\"#") {
+		return line
+	}
+	return name + " " + strings.TrimSpace(line[i+len(sep):])
 }
 
 // Load executes each line in the given config file.
 // If the any convars in the file are not registered with RegVar before calling this method, they will be ignored.
+// Loading counts as a nested execution, so a config file that ends up loading itself fails once the maximum depth
+// set by SetMaxDepth is reached.
+// Lines that are INI-like section headers, for ex. [Graphics], are skipped.
 func (c *Console) Load(filePath string) error {
-	file, err := os.Open(filePath)
+	return c.load(execState{}, filePath)
+}
+
+// load is like Load but executes the lines with the privilege level of the given execution state, if it's limited.
+func (c *Console) load(state execState, filePath string) error {
+	if err := c.enter(); err != nil {
+		return err
+	}
+	defer c.leave()
+
+	data, err := c.files().ReadFile(filePath)
 	if err != nil {
 		return err
 	}
-	scanner := bufio.NewScanner(file)
+	ctx := context.WithValue(context.Background(), execStateKey{}, execState{limited: state.limited, level: state.level})
+	scanner := bufio.NewScanner(bytes.NewReader(data))
 	scanner.Split(bufio.ScanLines)
 	for scanner.Scan() {
-		c.exec(true, scanner.Text())
+		c.exec(ctx, true, c.configCmd(scanner.Text()))
 	}
+	c.MarkClean()
 	return nil
 }
+
+// LoadError is an error of a single line of a config file, returned by Validate.
+type LoadError struct {
+	// Line is the line number, starting from 1.
+	Line int
+	// Text is the content of the line.
+	Text string
+	// Err is the error of the line.
+	Err error
+}
+
+func (e LoadError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// Unwrap returns the error of the line.
+func (e LoadError) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks the given config file like ValidateFrom.
+func (c *Console) Validate(filePath string) ([]LoadError, error) {
+	data, err := c.files().ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return c.ValidateFrom(bytes.NewReader(data))
+}
+
+// ValidateFrom checks each line of a config file read from r without executing it, for ex. to reject a downloaded
+// config before it affects the game. A line fails if its convar doesn't exist or its value is not valid for the convar,
+// exactly as if it's loaded via Load, but no values are set and no callbacks are triggered.
+// Lines that Load would ignore, like func convars that can't be used in config files, don't fail.
+// The returned error is only set if reading from r fails.
+func (c *Console) ValidateFrom(r io.Reader) ([]LoadError, error) {
+	var errs []LoadError
+	ctx := context.WithValue(context.Background(), execStateKey{}, execState{dryRun: true})
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if _, _, err := c.exec(ctx, true, c.configCmd(scanner.Text())); err != nil {
+			errs = append(errs, LoadError{Line: line, Text: scanner.Text(), Err: err})
+		}
+	}
+	return errs, scanner.Err()
+}
@@ -0,0 +1,366 @@
+// Copyright © 2020 Cosku Bas. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package convar
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// roundTrip saves the console to a memory storage, resets all convars and loads the file back.
+// It returns the saved file.
+func roundTrip(t *testing.T, c *Console) string {
+	t.Helper()
+	storage := &memStorage{files: make(map[string][]byte), hook: func() {}}
+	c.SetStorage(storage)
+	if err := c.Save("convars.ini"); err != nil {
+		t.Fatal(err)
+	}
+	c.ResetAllVar()
+	if err := c.Load("convars.ini"); err != nil {
+		t.Fatal(err)
+	}
+	return string(storage.files["convars.ini"])
+}
+
+func TestBoolRoundTrip(t *testing.T) {
+	for _, value := range []int{1, 2, -1} {
+		c := NewConsole(100, LogError, "", "", "")
+		cv := NewConVar("r_flags", reflect.Int, false, "", 0, nil).AsBool()
+		c.RegConVar(cv)
+		cv.SetInt(value)
+		file := roundTrip(t, c)
+		if got := cv.MustInt(); got != value {
+			t.Errorf("r_flags %d is loaded back as %d from %q", value, got, file)
+		}
+	}
+}
+
+func TestBoolDisplay(t *testing.T) {
+	cv := NewConVar("r_flags", reflect.Int, false, "", 0, nil).AsBool()
+	for value, want := range map[int]string{0: "false", 1: "true", 2: "2"} {
+		cv.value.Store(value)
+		if got := cv.DisplayValue(); got != want {
+			t.Errorf("DisplayValue of %d = %q, want %q", value, got, want)
+		}
+		if got := cv.AsCommand(); got != "r_flags "+want {
+			t.Errorf("AsCommand of %d = %q, want %q", value, got, "r_flags "+want)
+		}
+	}
+}
+
+func TestDollarRoundTrip(t *testing.T) {
+	for _, value := range []string{"$5", "$5 each", `\$x`, "a b", " padded ", `"quoted"`, ""} {
+		c := NewConsole(100, LogError, "", "", "")
+		cv := NewConVar("cl_price", reflect.String, false, "", "", nil)
+		c.RegConVar(NewConVar("5", reflect.String, false, "", "", nil))
+		c.RegConVar(cv)
+		cv.SetString(value)
+
+		file := roundTrip(t, c)
+		if got := cv.MustString(); got != value {
+			t.Errorf("%q is loaded back as %q from %q", value, got, file)
+		}
+		errs, err := c.Validate("convars.ini")
+		if err != nil || len(errs) > 0 {
+			t.Errorf("Validate of %q = %v, %v", file, errs, err)
+		}
+
+		cmd := cv.AsCommand()
+		cv.SetString("other")
+		if _, err := c.ExecCmd(cmd); err != nil {
+			t.Errorf("%q: %v", cmd, err)
+		}
+		if got := cv.MustString(); got != value {
+			t.Errorf("%q sets %q, want %q", cmd, got, value)
+		}
+	}
+
+	// Custom formatters may return an empty value as it is
+	c := NewConsole(100, LogError, "", "", "")
+	c.SetFormatter(reflect.String, func(value interface{}) string { return value.(string) })
+	cv := NewConVar("cl_price", reflect.String, false, "", "", nil)
+	c.RegConVar(cv)
+	if got, want := cv.AsCommand(), `cl_price ""`; got != want {
+		t.Errorf("AsCommand with a custom formatter = %q, want %q", got, want)
+	}
+}
+
+func TestFloatRoundTrip(t *testing.T) {
+	for _, value := range []float64{0.1 + 0.2, 1e-300, 123456789.123456789, -0.5, math.MaxFloat64} {
+		c := NewConsole(100, LogError, "", "", "")
+		cv := NewConVar("sensitivity", reflect.Float64, false, "", 1.0, nil)
+		c.RegConVar(cv)
+		cv.SetFloat64(value)
+		file := roundTrip(t, c)
+		if got := cv.MustFloat64(); math.Float64bits(got) != math.Float64bits(value) {
+			t.Errorf("%v is loaded back as %v from %q", value, got, file)
+		}
+	}
+}
+
+func TestLoadSelfReferential(t *testing.T) {
+	c := NewConsole(100, LogError, "", "", "")
+	c.SetStorage(&memStorage{files: map[string][]byte{"self.cfg": []byte("exec self.cfg\n")}, hook: func() {}})
+	c.SetMaxDepth(4)
+	var (
+		loads int
+		err   error
+	)
+	// exec is like the exec command of Quake, which runs a config file and can be used within config files
+	exec := NewConVar("exec", reflect.String, true, "", "", func(con *Console, oldVal, newVal interface{}) {
+		loads++
+		if e := con.Load(newVal.(string)); e != nil {
+			err = e
+		}
+	})
+	exec.fileSafe = true
+	c.RegConVar(exec)
+
+	noDeadlock(t, "self-referential config", func() { c.ExecCmd("exec self.cfg") })
+	if loads != 5 {
+		t.Errorf("exec is run %d times, want 5", loads)
+	}
+	if want := "maximum nesting depth of 4 is exceeded"; err == nil || err.Error() != want {
+		t.Errorf("error = %v, want %q", err, want)
+	}
+	if c.depth != 0 {
+		t.Errorf("depth is %d after loading, want 0", c.depth)
+	}
+}
+
+func TestSaveDeterministic(t *testing.T) {
+	names := []string{"sv_gravity", "cl_width", "r_fov", "cl_height", "a_volume"}
+	save := func(order []string, category bool) []byte {
+		c := NewConsole(100, LogError, "", "", "")
+		for _, name := range order {
+			cv := NewConVar(name, reflect.Int, false, "", 0, nil)
+			if category && len(name)%2 == 0 {
+				cv.SetCategory("Graphics")
+			}
+			c.RegConVar(cv)
+			cv.SetInt(len(name))
+		}
+		storage := &memStorage{files: make(map[string][]byte), hook: func() {}}
+		c.SetStorage(storage)
+		if err := c.Save("first.ini"); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Save("second.ini"); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(storage.files["first.ini"], storage.files["second.ini"]) {
+			t.Fatalf("saving twice differs:\n%s\n---\n%s", storage.files["first.ini"], storage.files["second.ini"])
+		}
+		return storage.files["first.ini"]
+	}
+
+	for _, category := range []bool{false, true} {
+		want := save(names, category)
+		reversed := make([]string, len(names))
+		for i, name := range names {
+			reversed[len(names)-1-i] = name
+		}
+		for i := 0; i < 10; i++ {
+			order := names
+			if i%2 == 1 {
+				order = reversed
+			}
+			if got := save(order, category); !bytes.Equal(got, want) {
+				t.Fatalf("saved files differ between consoles:\n%s\n---\n%s", want, got)
+			}
+		}
+	}
+}
+
+func TestLoadSectionHeaders(t *testing.T) {
+	const file = "[Graphics]\ncl_width 800\n\n  [Audio and Music]  \nvolume 5\n[]\ncl_height 600\n"
+	c := NewConsole(100, LogError, "", "", "")
+	width := NewConVar("cl_width", reflect.Int, false, "", 640, nil)
+	height := NewConVar("cl_height", reflect.Int, false, "", 480, nil)
+	volume := NewConVar("volume", reflect.Int, false, "", 10, nil)
+	c.RegConVar(width)
+	c.RegConVar(height)
+	c.RegConVar(volume)
+	c.SetStorage(&memStorage{files: map[string][]byte{"convars.ini": []byte(file)}, hook: func() {}})
+
+	errs, err := c.Validate("convars.ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 0 {
+		t.Errorf("Validate = %v, want no errors", errs)
+	}
+	if err := c.Load("convars.ini"); err != nil {
+		t.Fatal(err)
+	}
+	if width.MustInt() != 800 || height.MustInt() != 600 || volume.MustInt() != 5 {
+		t.Errorf("loaded %d, %d, %d, want 800, 600, 5", width.MustInt(), height.MustInt(), volume.MustInt())
+	}
+
+	// Typed commands aren't config files
+	if _, err := c.ExecCmd("[Graphics]"); err == nil {
+		t.Error("a typed section header doesn't fail")
+	}
+}
+
+func TestFileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions are not supported on windows")
+	}
+	dir := t.TempDir()
+	c := NewConsole(100, LogError, "", "", "")
+	cv := NewConVar("cl_width", reflect.Int, false, "", 640, nil)
+	c.RegConVar(cv)
+	cv.SetInt(800)
+
+	mode := func(name string) os.FileMode {
+		t.Helper()
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Mode().Perm()
+	}
+
+	// The default mode is subject to the umask, which can only clear bits
+	if err := c.Save(filepath.Join(dir, "default.ini")); err != nil {
+		t.Fatal(err)
+	}
+	if got := mode("default.ini"); got&^defaultFileMode != 0 || got&0600 != 0600 {
+		t.Errorf("default mode = %v, want at most %v", got, defaultFileMode)
+	}
+
+	// 0600 is kept as it is by any sane umask
+	c.SetFileMode(0600)
+	if err := c.Save(filepath.Join(dir, "private.ini")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DumpBuffer(filepath.Join(dir, "private.log")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SaveMerge(filepath.Join(dir, "merged.ini")); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"private.ini", "private.log", "merged.ini"} {
+		if got := mode(name); got != 0600 {
+			t.Errorf("%s mode = %v, want %v", name, got, os.FileMode(0600))
+		}
+	}
+
+	c.SetFileMode(0)
+	if got := c.perm(); got != defaultFileMode {
+		t.Errorf("mode after SetFileMode(0) = %v, want %v", got, defaultFileMode)
+	}
+}
+
+func TestNumericValueSpacing(t *testing.T) {
+	c := NewConsole(100, LogError, "", "", "")
+	width := NewConVar("cl_width", reflect.Int, false, "", 640, nil)
+	scale := NewConVar("cl_scale", reflect.Float64, false, "", 1.0, nil)
+	c.RegConVar(width)
+	c.RegConVar(scale)
+
+	for cmd, want := range map[string]int{
+		"cl_width   800   ":          800,
+		"cl_width\t\t1024\t":         1024,
+		"  cl_width \t 1280":         1280,
+		"cl_width 1600 # was 1280":   1600,
+		"cl_width\t1920\t#\tcomment": 1920,
+	} {
+		if _, err := c.ExecCmd(cmd); err != nil {
+			t.Errorf("%q: %v", cmd, err)
+		} else if got := width.MustInt(); got != want {
+			t.Errorf("%q: cl_width = %d, want %d", cmd, got, want)
+		}
+	}
+	for cmd, want := range map[string]float64{
+		"cl_scale    1.5  ":      1.5,
+		"cl_scale\t-0.25\t":      -0.25,
+		"cl_scale  2e-1  #small": 0.2,
+	} {
+		if _, err := c.ExecCmd(cmd); err != nil {
+			t.Errorf("%q: %v", cmd, err)
+		} else if got := scale.MustFloat64(); got != want {
+			t.Errorf("%q: cl_scale = %v, want %v", cmd, got, want)
+		}
+	}
+
+	width.SetInt(640)
+	for _, cmd := range []string{"cl_width 800 600", "cl_width 800\t600 # comment", "cl_width 800#comment"} {
+		if _, err := c.ExecCmd(cmd); err == nil {
+			t.Errorf("%q doesn't fail", cmd)
+		}
+	}
+	if got := width.MustInt(); got != 640 {
+		t.Errorf("cl_width = %d after the invalid commands, want 640", got)
+	}
+
+	const file = "cl_width   800   \ncl_width 800 600\ncl_scale\t1.5 # comment\n"
+	c.SetStorage(&memStorage{files: map[string][]byte{"convars.ini": []byte(file)}, hook: func() {}})
+	errs, err := c.Validate("convars.ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 1 || errs[0].Line != 2 {
+		t.Errorf("Validate = %v, want an error on line 2", errs)
+	}
+}
+
+func TestEmptyStringRoundTrip(t *testing.T) {
+	c := NewConsole(100, LogError, "", "", "")
+	cv := NewConVar("name", reflect.String, false, "", "player", nil)
+	c.RegConVar(cv)
+	cv.SetString("")
+
+	file := roundTrip(t, c)
+	if file != "name \"\"\n" {
+		t.Errorf("saved file = %q, want %q", file, "name \"\"\n")
+	}
+	if got := cv.MustString(); got != "" {
+		t.Errorf("empty name is loaded back as %q from %q", got, file)
+	}
+}
+
+func TestSaveMerge(t *testing.T) {
+	merge := func(file string, vars map[string]string) string {
+		t.Helper()
+		c := NewConsole(100, LogError, "", "", "")
+		storage := &memStorage{files: map[string][]byte{"convars.ini": []byte(file)}, hook: func() {}}
+		c.SetStorage(storage)
+		for name, category := range vars {
+			cv := NewConVar(name, reflect.Int, false, "", 0, nil)
+			cv.SetCategory(category)
+			c.RegConVar(cv)
+			cv.SetInt(len(name))
+		}
+		if err := c.SaveMerge("convars.ini"); err != nil {
+			t.Fatal(err)
+		}
+		return string(storage.files["convars.ini"])
+	}
+
+	// New convars go to the end of their sections and the missing sections are appended
+	file := "# user settings\n[Graphics]\ncl_width 800 # tuned\n\n[Audio]\nvolume 5\t# loud\n"
+	want := "# user settings\n[Graphics]\ncl_width 8 # tuned\ncl_height 9\n\n[Audio]\nvolume 6\t# loud\nmusic 5\n" +
+		"\n[General]\nfov 3\n\n[Input]\nsensitivity 11\n"
+	vars := map[string]string{"cl_width": "Graphics", "cl_height": "Graphics", "volume": "Audio", "music": "Audio",
+		"sensitivity": "Input", "fov": ""}
+	if got := merge(file, vars); got != want {
+		t.Errorf("merged file = %q, want %q", got, want)
+	}
+
+	// Without sections, the convars without a category are appended at the end
+	file = "cl_width 800  # tuned\nbind f3 \"say gg\"\n"
+	want = "cl_width 8  # tuned\nbind f3 \"say gg\"\nfov 3\n\n[Input]\nsensitivity 11\n"
+	if got := merge(file, map[string]string{"cl_width": "", "fov": "", "sensitivity": "Input"}); got != want {
+		t.Errorf("merged file = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright © 2020 Cosku Bas. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package convar
+
+import (
+	"sync/atomic"
+)
+
+// BeginFrame starts recording the convars whose value is changed, for ex. at the start of a game frame.
+// Calling it again restarts the recording.
+func (c *Console) BeginFrame() {
+	c.frameLock.Lock()
+	defer c.frameLock.Unlock()
+	c.frameSeen = make(map[*ConVar]bool)
+	c.frameCvs = nil
+	atomic.StoreInt32(&c.framing, 1)
+}
+
+// EndFrame stops the recording started by BeginFrame and returns the convars whose value is changed since then,
+// each once, in the order of their first change. Any change counts, including resets. It returns nil if no
+// recording is in progress.
+func (c *Console) EndFrame() []*ConVar {
+	c.frameLock.Lock()
+	defer c.frameLock.Unlock()
+	atomic.StoreInt32(&c.framing, 0)
+	cvs := c.frameCvs
+	c.frameSeen, c.frameCvs = nil, nil
+	return cvs
+}
+
+// recordChange marks the console as dirty if the convar is saved to config files, see IsDirty,
+// and adds the convar to the changes of the current frame, if any.
+func (c *Console) recordChange(cv *ConVar) {
+	if c == nil {
+		return
+	}
+	if !cv.isFunc && cv.getter == nil {
+		atomic.StoreInt32(&c.dirty, 1)
+	}
+	if atomic.LoadInt32(&c.framing) == 0 {
+		return
+	}
+	c.frameLock.Lock()
+	defer c.frameLock.Unlock()
+	if c.frameSeen != nil && !c.frameSeen[cv] {
+		c.frameSeen[cv] = true
+		c.frameCvs = append(c.frameCvs, cv)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright © 2020 Cosku Bas. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package convar
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+type jsonRequest struct {
+	Cmd *string `json:"cmd"`
+	Get *string `json:"get"`
+}
+
+type jsonResponse struct {
+	Name  string      `json:"name,omitempty"`
+	Type  string      `json:"type,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+	Error string      `json:"error,omitempty"`
+}
+
+// HandleJSON decodes a JSON request, dispatches it to the console and returns the JSON encoded response.
+// It's meant for external tools driving the console, over any transport set up by the user.
+//
+// A request is either {"cmd":"cl_width 800"}, which executes the command like ExecCmd,
+// or {"get":"cl_width"}, which reads the value of the convar.
+// The response holds the name, the type and the current value of the resolved convar, for ex.
+// {"name":"cl_width","type":"Integer","value":800}, and an "error" field if the request failed.
+// The value of a convar marked with ConVar.WriteOnly is never included, and getting it is an error.
+func (c *Console) HandleJSON(request []byte) (response []byte) {
+	var (
+		req  jsonRequest
+		resp jsonResponse
+		cv   *ConVar
+		err  error
+	)
+	if err = json.Unmarshal(request, &req); err == nil {
+		switch {
+		case req.Cmd != nil && req.Get == nil:
+			cv, err = c.ExecCmd(*req.Cmd)
+		case req.Get != nil && req.Cmd == nil:
+			if cv, err = c.lookup(*req.Get); err == nil && cv.isWriteOnly() {
+				cv, err = nil, fmt.Errorf(c.str().ErrWriteOnly, cv.varName)
+			}
+		default:
+			err = errors.New(c.str().ErrBadRequest)
+		}
+	}
+	if cv != nil {
+		resp.Name, resp.Type = cv.varName, cv.TypeName()
+		if !cv.isWriteOnly() {
+			resp.Value = cv.load()
+		}
+	}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	response, err = json.Marshal(resp)
+	if err != nil {
+		// Only the value can fail to encode, for ex. a NaN float, in which case it's sent as text instead
+		resp.Value = cv.DisplayValue()
+		response, _ = json.Marshal(resp)
+	}
+	return response
+}
@@ -0,0 +1,70 @@
+// Copyright © 2020 Cosku Bas. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package convar
+
+import (
+	"context"
+	"strings"
+)
+
+// Bind binds a command to the given key, which is later executed via ExecBind. Key names are case insensitive.
+// The command is stored verbatim, so it executes exactly as if it was typed into the console.
+func (c *Console) Bind(key, cmd string) {
+	c.bindLock.Lock()
+	defer c.bindLock.Unlock()
+	c.binds[strings.ToLower(key)] = strings.TrimSpace(cmd)
+}
+
+// Unbind removes the command bound to the given key.
+func (c *Console) Unbind(key string) {
+	c.bindLock.Lock()
+	defer c.bindLock.Unlock()
+	delete(c.binds, strings.ToLower(key))
+}
+
+// Binding returns the command bound to the given key. ok is false if the key is not bound.
+func (c *Console) Binding(key string) (cmd string, ok bool) {
+	c.bindLock.RLock()
+	defer c.bindLock.RUnlock()
+	cmd, ok = c.binds[strings.ToLower(key)]
+	return cmd, ok
+}
+
+// ExecBind executes the command bound to the given key like ExecCmd.
+// If the key is not bound, nothing is executed and both the convar and the error are nil.
+func (c *Console) ExecBind(key string) (*ConVar, error) {
+	cmd, ok := c.Binding(key)
+	if !ok {
+		return nil, nil
+	}
+	return c.ExecCmd(cmd)
+}
+
+// DefaultBindFunc is the callback of the bind command, which takes a key followed by a command.
+// The command can either be a single quoted token, for ex. bind f3 "say \"gg wp\"", or the rest of the line.
+// bind is a greedy convar, so the command keeps its case and is tokenized here rather than by the console.
+func DefaultBindFunc(ctx context.Context, con *Console, oldVal, newVal interface{}) {
+	key, rest, err := nextToken(newVal.(string))
+	if err != nil {
+		con.LogErrorf("%v", con.tokenErr(err))
+		return
+	}
+	if key == "" {
+		con.LogErrorf("%s", con.str().ErrNilValue)
+		return
+	}
+	if rest == "" {
+		if cmd, ok := con.Binding(key); ok {
+			con.Outputf(ctx, "%s %s", key, quote(cmd))
+		} else {
+			con.Outputf(ctx, con.str().MsgNotBound, key)
+		}
+		return
+	}
+	if tokens, err := tokenize(rest); err == nil && len(tokens) == 1 {
+		rest = tokens[0]
+	}
+	con.Bind(key, rest)
+}
@@ -0,0 +1,64 @@
+// Copyright © 2020 Cosku Bas. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package convar
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newSayConsole returns a console with the default convars and a say command that records what it receives.
+func newSayConsole(said *[]string) *Console {
+	c := NewConsole(100, LogError, "", "", "")
+	c.RegDefaultConVars()
+	c.RegConVar(NewRawCmd("say", "", func(con *Console, raw string) {
+		*said = append(*said, raw)
+	}))
+	return c
+}
+
+func TestBindKeepsCase(t *testing.T) {
+	var said []string
+	c := newSayConsole(&said)
+	if _, err := c.ExecCmd(`bind F3 "say Hello World"`); err != nil {
+		t.Fatal(err)
+	}
+	if cmd, ok := c.Binding("f3"); !ok || cmd != "say Hello World" {
+		t.Fatalf("Binding(f3) = %q, %v, want %q", cmd, ok, "say Hello World")
+	}
+	if _, err := c.ExecBind("f3"); err != nil {
+		t.Fatal(err)
+	}
+	if len(said) != 1 || said[0] != "Hello World" {
+		t.Errorf("say received %q, want [Hello World]", said)
+	}
+}
+
+func TestBindQuotedArgument(t *testing.T) {
+	for _, cmd := range []string{`say "gg wp"`, `say gg  "wp"`, `name "Gg Wp"`} {
+		var typed, bound []string
+		c := newSayConsole(&typed)
+		c.RegConVar(NewConVar("name", reflect.String, false, "", "", nil))
+		if _, err := c.ExecCmd(cmd); err != nil {
+			t.Fatal(err)
+		}
+		want, _ := c.GetString("name")
+
+		c = newSayConsole(&bound)
+		c.RegConVar(NewConVar("name", reflect.String, false, "", "", nil))
+		if _, err := c.ExecCmd("bind f3 " + quote(cmd)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.ExecBind("f3"); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(bound, typed) {
+			t.Errorf("%q: bound say received %q, typed %q", cmd, bound, typed)
+		}
+		if got, _ := c.GetString("name"); got != want {
+			t.Errorf("%q: bound name = %q, typed %q", cmd, got, want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright © 2020 Cosku Bas. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package convar
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// noDeadlock fails the test if fn doesn't return in time, which means it's blocked on a console lock.
+func noDeadlock(t *testing.T, name string, fn func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s is deadlocked", name)
+	}
+}
+
+// memStorage is a storage that keeps the files in memory and calls hook on every write.
+type memStorage struct {
+	files map[string][]byte
+	hook  func()
+}
+
+func (s *memStorage) ReadFile(name string) ([]byte, error) {
+	data, ok := s.files[name]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return data, nil
+}
+
+func (s *memStorage) WriteFile(name string, data []byte, perm os.FileMode) error {
+	s.hook()
+	s.files[name] = data
+	return nil
+}
+
+func TestCallbacksUseConsole(t *testing.T) {
+	c := NewConsole(100, LogError, "", "", "")
+	c.RegConVar(NewConVar("other", reflect.Int, false, "", 0, nil))
+	c.RegConVar(NewConVar("reg", reflect.Int, false, "", 0, func(con *Console, oldVal, newVal interface{}) {
+		con.RegConVar(NewConVar("added", reflect.Int, false, "", 0, nil))
+	}))
+	c.RegConVar(NewConVar("unreg", reflect.Int, false, "", 0, func(con *Console, oldVal, newVal interface{}) {
+		con.UnregConVar("other")
+	}))
+	c.RegConVar(NewConVar("reset", reflect.Int, false, "", 0, func(con *Console, oldVal, newVal interface{}) {
+		if newVal.(int) != 0 {
+			con.ResetAllVarNotify()
+		}
+	}))
+
+	noDeadlock(t, "RegConVar from a callback", func() { c.ExecCmd("reg 1") })
+	if !c.Exists("added") {
+		t.Error("convar registered by a callback doesn't exist")
+	}
+	noDeadlock(t, "UnregConVar from a callback", func() { c.ExecCmd("unreg 1") })
+	if c.Exists("other") {
+		t.Error("convar unregistered by a callback still exists")
+	}
+	noDeadlock(t, "ResetAllVarNotify from a callback", func() { c.ExecCmd("reset 1") })
+	for _, name := range []string{"reg", "unreg", "reset"} {
+		if got := c.ConVar(name).MustInt(); got != 0 {
+			t.Errorf("%s = %d after ResetAllVarNotify, want 0", name, got)
+		}
+	}
+}
+
+func TestReadGroupGetterSets(t *testing.T) {
+	c := NewConsole(100, LogError, "", "", "")
+	c.RegConVar(NewConVar("a", reflect.Int, false, "", 0, nil))
+	c.RegConVar(NewComputedConVar("fps", reflect.Int, "", func() interface{} {
+		c.ConVar("a").SetInt(1)
+		return 60
+	}))
+	noDeadlock(t, "ReadGroup with a getter that sets a convar", func() {
+		values, err := c.ReadGroup("a", "fps")
+		if err != nil {
+			t.Error(err)
+		}
+		if values["fps"] != 60 {
+			t.Errorf("fps = %v, want 60", values["fps"])
+		}
+	})
+}
+
+func TestSaveUserCodeUsesConsole(t *testing.T) {
+	c := NewConsole(100, LogError, "", "", "")
+	c.RegConVar(NewConVar("name", reflect.String, false, "", "", nil))
+	c.ConVar("name").SetString("player")
+	c.SetFormatter(reflect.String, func(value interface{}) string {
+		c.RegConVar(NewConVar("formatted", reflect.Int, false, "", 0, nil))
+		return value.(string)
+	})
+	storage := &memStorage{files: make(map[string][]byte)}
+	storage.hook = func() { c.UnregConVar("formatted") }
+	c.SetStorage(storage)
+
+	noDeadlock(t, "Save", func() {
+		if err := c.Save("convars.ini"); err != nil {
+			t.Error(err)
+		}
+	})
+	noDeadlock(t, "SaveMerge", func() {
+		if err := c.SaveMerge("convars.ini"); err != nil {
+			t.Error(err)
+		}
+	})
+	if got := string(storage.files["convars.ini"]); got != "name player\n" {
+		t.Errorf("saved %q, want %q", got, "name player\n")
+	}
+}
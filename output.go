@@ -0,0 +1,46 @@
+// Copyright © 2020 Cosku Bas. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package convar
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// output collects the output of the commands executed via ExecCmdOutput.
+type output struct {
+	lock  sync.Mutex
+	lines []string
+}
+
+// ExecCmdOutput is like ExecCmd but also returns the output of the command, for ex. the list printed by var_list,
+// so that a caller can use it without scraping the buffer. The output consists of the lines written by the
+// callbacks via Outputf, including the ones of the default convars, or the value of the convar for a query.
+// Lines are separated by newlines. The output is still logged to the buffer as usual.
+func (c *Console) ExecCmdOutput(cmd string) (cv *ConVar, out string, err error) {
+	o := &output{}
+	ctx := context.WithValue(context.Background(), execStateKey{}, execState{out: o})
+	cv, _, err = c.exec(ctx, false, cmd)
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return cv, strings.Join(o.lines, "\n"), err
+}
+
+// Outputf logs an information message like LogInfof and also adds it to the output returned by ExecCmdOutput
+// when ctx belongs to such an execution. It's meant for context-aware callbacks that print the result of a command.
+func (c *Console) Outputf(ctx context.Context, format string, a ...interface{}) {
+	c.LogInfof(format, a...)
+	if o := stateFrom(ctx).out; o != nil {
+		o.add(fmt.Sprintf(format, a...))
+	}
+}
+
+func (o *output) add(line string) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.lines = append(o.lines, line)
+}
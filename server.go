@@ -0,0 +1,159 @@
+// Copyright © 2020 Cosku Bas. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package convar
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"sync/atomic"
+)
+
+// PasswordConVar is the name of the string convar that holds the password of the remote console served by Serve.
+const PasswordConVar = "rcon_password"
+
+// Number of log messages that can be queued for a slow client before the following ones are dropped.
+const serveQueueLen = 256
+
+// Serve accepts connections on l and serves a remote console on each of them, like the rcon of Quake-style servers.
+// Commands are read line by line and executed via ExecCmdPriv with the given privilege level, so only the convars
+// that don't require a higher privilege can be used remotely. Errors are written back to the client, and so is the
+// value of the convar when echo mode is disabled. All messages written to the console buffer are streamed to
+// the client while it's connected. Messages are dropped for a client that can't keep up, so that it can't block
+// the console.
+//
+// The console must have a string convar named PasswordConVar that is marked with ConVar.WriteOnly, so that clients
+// can't read it back, otherwise Serve returns an error without accepting any connections. The first line sent by
+// the client must be the password, and the connection is closed if it doesn't match or the password is empty.
+// Use ServeNoPassword to serve without a password.
+//
+// Serve blocks until l.Accept returns an error, which is then returned. Closing l stops the server,
+// but not the connections that are already accepted.
+func (c *Console) Serve(l net.Listener, level int) error {
+	cv := c.ConVar(PasswordConVar)
+	if cv == nil || cv.varType != reflect.String {
+		return errors.New(c.str().ErrNoPassword)
+	}
+	if !cv.isWriteOnly() {
+		return fmt.Errorf(c.str().ErrNotWriteOnly, cv.varName)
+	}
+	return c.serve(l, level, true)
+}
+
+// ServeNoPassword is like Serve but doesn't ask for a password, so anyone who can connect to l can execute
+// the commands allowed by the privilege level. It should only be used on a listener that is already
+// protected, for ex. one that only accepts local connections.
+func (c *Console) ServeNoPassword(l net.Listener, level int) error {
+	return c.serve(l, level, false)
+}
+
+func (c *Console) serve(l net.Listener, level int, password bool) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go c.serveConn(conn, level, password)
+	}
+}
+
+// checkPassword reads the password from the client and reports whether it matches the password convar.
+// The convar is looked up again since it may be replaced after Serve is called.
+func (c *Console) checkPassword(scanner *bufio.Scanner) (ok bool, err error) {
+	cv := c.ConVar(PasswordConVar)
+	if cv == nil || cv.varType != reflect.String || !cv.isWriteOnly() || cv.load().(string) == "" {
+		return false, errors.New(c.str().ErrNoPassword)
+	}
+	password := cv.load().(string)
+	if !scanner.Scan() {
+		return false, nil
+	}
+	given := strings.TrimRight(scanner.Text(), "\r")
+	return subtle.ConstantTimeCompare([]byte(given), []byte(password)) == 1, nil
+}
+
+func (c *Console) serveConn(conn net.Conn, level int, password bool) {
+	// out is never closed since log hooks can still be running after they are removed
+	out := make(chan string, serveQueueLen)
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		write := func(line string) bool {
+			if _, err := conn.Write([]byte(line + "\n")); err != nil {
+				// Closing the connection also stops the reader below
+				conn.Close()
+				return false
+			}
+			return true
+		}
+		for {
+			select {
+			case line := <-out:
+				if !write(line) {
+					return
+				}
+			case <-quit:
+				// Flush what's queued before the connection is closed
+				for {
+					select {
+					case line := <-out:
+						if !write(line) {
+							return
+						}
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+	defer func() {
+		close(quit)
+		<-done
+		conn.Close()
+	}()
+	send := func(line string) {
+		select {
+		case out <- line:
+		case <-done:
+		}
+	}
+
+	scanner := bufio.NewScanner(conn)
+	if password {
+		ok, err := c.checkPassword(scanner)
+		if err != nil {
+			conn.Write([]byte(err.Error() + "\n"))
+			return
+		}
+		if !ok {
+			conn.Write([]byte(c.str().ErrBadPassword + "\n"))
+			return
+		}
+	}
+
+	remove := c.OnLog(func(level LogLevel, prefix, msg string) {
+		select {
+		case out <- prefix + msg:
+		default:
+		}
+	})
+	defer remove()
+
+	for scanner.Scan() {
+		cv, err := c.ExecCmdPriv(level, strings.TrimRight(scanner.Text(), "\r"))
+		switch {
+		case err != nil:
+			send(err.Error())
+		case cv != nil && !cv.isFunc && !cv.isWriteOnly() && atomic.LoadInt32(&c.echo) == 0:
+			send(cv.varName + " " + cv.DisplayValue())
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright © 2020 Cosku Bas. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package convar
+
+import (
+	"bufio"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveTest serves c on a local listener and returns a function that sends lines to a new connection
+// and returns the first reply.
+func serveTest(t *testing.T, c *Console, password bool) func(lines ...string) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	if password {
+		go c.Serve(l, 0)
+	} else {
+		go c.ServeNoPassword(l, 0)
+	}
+	return func(lines ...string) string {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		for _, line := range lines {
+			conn.Write([]byte(line + "\n"))
+		}
+		reply, _ := bufio.NewReader(conn).ReadString('\n')
+		return strings.TrimSuffix(reply, "\n")
+	}
+}
+
+func newServeConsole(password string) *Console {
+	c := NewConsole(100, LogError, "", "", "")
+	c.RegConVar(NewConVar("cl_width", reflect.Int, false, "", 640, nil))
+	cheats := NewConVar("sv_cheats", reflect.Int, false, "", 0, nil)
+	cheats.SetPrivilege(10)
+	c.RegConVar(cheats)
+	c.RegConVar(NewConVar(PasswordConVar, reflect.String, false, "", password, nil).WriteOnly())
+	return c
+}
+
+func TestServeRequiresPassword(t *testing.T) {
+	// An empty password closes every connection
+	send := serveTest(t, newServeConsole(""), true)
+	if got := send("", "cl_width 800"); got != errNoPassword {
+		t.Errorf("reply = %q, want %q", got, errNoPassword)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	c := NewConsole(100, LogError, "", "", "")
+	if err := c.Serve(l, 0); err == nil || err.Error() != errNoPassword {
+		t.Errorf("Serve without a password convar = %v, want %q", err, errNoPassword)
+	}
+	readable := NewConVar(PasswordConVar, reflect.String, false, "", "secret", nil)
+	c.RegConVar(readable)
+	if err, want := c.Serve(l, 0), "variable rcon_password must be write-only to hold a password"; err == nil || err.Error() != want {
+		t.Errorf("Serve with a readable password = %v, want %q", err, want)
+	}
+	if readable.isWriteOnly() {
+		t.Error("Serve marks the password convar as write-only")
+	}
+}
+
+func TestServePassword(t *testing.T) {
+	c := newServeConsole("secret")
+	send := serveTest(t, c, true)
+	if got := send("wrong", "cl_width 800"); got != errBadPassword {
+		t.Errorf("reply to a wrong password = %q, want %q", got, errBadPassword)
+	}
+	if got := send("secret", "cl_width 800"); got != "cl_width 800" {
+		t.Errorf("reply = %q, want cl_width 800", got)
+	}
+	if got, want := send("secret", "sv_cheats 1"), "not enough privilege to execute sv_cheats"; got != want {
+		t.Errorf("reply = %q, want %q", got, want)
+	}
+	for _, cmd := range []string{PasswordConVar, "cl_width $" + PasswordConVar} {
+		if got, want := send("secret", cmd), "variable rcon_password is write-only"; got != want {
+			t.Errorf("reply to %q = %q, want %q", cmd, got, want)
+		}
+	}
+}
+
+func TestServeNoPassword(t *testing.T) {
+	send := serveTest(t, newServeConsole(""), false)
+	if got := send("cl_width 800"); got != "cl_width 800" {
+		t.Errorf("reply = %q, want cl_width 800", got)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright © 2020 Cosku Bas. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+//go:build go1.21
+// +build go1.21
+
+package convar
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+type slogHandler struct {
+	c      *Console
+	attrs  string // Preformatted attributes given to WithAttrs
+	prefix string // Group prefix of the keys, for ex. "req."
+}
+
+// SlogHandler returns a slog.Handler that writes the records to the console buffer, so that code using log/slog
+// shows up in the console. Records are written as the message followed by the attributes as key=value pairs.
+// Levels below slog.LevelWarn are logged as LogInfo, levels below slog.LevelError as LogWarning
+// and the rest as LogError. Records are only written if their level is enabled by the log level of the console,
+// as described by LogLevel, for ex. a console with LogWarning drops the records of slog.LevelError.
+func (c *Console) SlogHandler() slog.Handler {
+	return &slogHandler{c: c}
+}
+
+func slogLevel(level slog.Level) LogLevel {
+	switch {
+	case level < slog.LevelWarn:
+		return LogInfo
+	case level < slog.LevelError:
+		return LogWarning
+	default:
+		return LogError
+	}
+}
+
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return (int32)(slogLevel(level)) <= atomic.LoadInt32((*int32)(&h.c.logLevel))
+}
+
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if !h.Enabled(ctx, r.Level) {
+		// slog.Logger checks Enabled first, but Handle may be called directly
+		return nil
+	}
+	var b strings.Builder
+	b.WriteString(r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		appendAttr(&b, h.prefix, a)
+		return true
+	})
+
+	level := slogLevel(r.Level)
+	var prefix string
+	switch level {
+	case LogInfo:
+		prefix = h.c.logInfoPrefix
+	case LogWarning:
+		prefix = h.c.logWarnPrefix
+	default:
+		prefix = h.c.logErrPrefix
+	}
+	h.c.log(level, prefix, "%s", b.String())
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	b.WriteString(h.attrs)
+	for _, a := range attrs {
+		appendAttr(&b, h.prefix, a)
+	}
+	return &slogHandler{c: h.c, attrs: b.String(), prefix: h.prefix}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogHandler{c: h.c, attrs: h.attrs, prefix: h.prefix + name + "."}
+}
+
+func appendAttr(b *strings.Builder, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		// Attributes of a group without a key are inlined as per the slog.Handler rules
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			appendAttr(b, prefix, ga)
+		}
+		return
+	}
+	value := a.Value.String()
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		value = strconv.Quote(value)
+	}
+	b.WriteString(" ")
+	b.WriteString(prefix)
+	b.WriteString(a.Key)
+	b.WriteString("=")
+	b.WriteString(value)
+}
@@ -0,0 +1,59 @@
+// Copyright © 2020 Cosku Bas. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+//go:build go1.21
+// +build go1.21
+
+package convar
+
+import (
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestSlogHandler(t *testing.T) {
+	c := NewConsole(100, LogError, "I: ", "W: ", "E: ")
+	logger := slog.New(c.SlogHandler())
+
+	logger.Debug("debug")
+	logger.Info("connected", "addr", "127.0.0.1:27015", "players", 3)
+	logger.With("map", "de dust").WithGroup("req").Warn("slow", slog.Group("time", "ms", 250), "id", "")
+	logger.Error("failed", slog.Group("", "code", 7))
+
+	want := []string{
+		"I: debug",
+		"I: connected addr=127.0.0.1:27015 players=3",
+		`W: slow map="de dust" req.time.ms=250 req.id=""`,
+		"E: failed code=7",
+	}
+	if got := c.BufferRaw(); !reflect.DeepEqual(got, want) {
+		t.Errorf("buffer = %q, want %q", got, want)
+	}
+}
+
+func TestSlogHandlerLevels(t *testing.T) {
+	for level, want := range map[LogLevel][]string{
+		LogNone:    {},
+		LogInfo:    {"I: info"},
+		LogWarning: {"I: info", "W: warning"},
+		LogError:   {"I: info", "W: warning", "E: error"},
+	} {
+		c := NewConsole(10, level, "I: ", "W: ", "E: ")
+		logger := slog.New(c.SlogHandler())
+		logger.Info("info")
+		logger.Warn("warning")
+		logger.Error("error")
+		if got := c.BufferRaw(); !reflect.DeepEqual(got, want) {
+			t.Errorf("level %d: buffer = %q, want %q", level, got, want)
+		}
+	}
+
+	// The Log* functions keep writing warnings and errors whenever information messages are written
+	c := NewConsole(10, LogInfo, "I: ", "W: ", "E: ")
+	c.LogErrorf("error")
+	if got := c.BufferRaw(); !reflect.DeepEqual(got, []string{"E: error"}) {
+		t.Errorf("LogErrorf with LogInfo: buffer = %q", got)
+	}
+}
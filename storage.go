@@ -0,0 +1,69 @@
+// Copyright © 2020 Cosku Bas. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package convar
+
+import (
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+)
+
+// defaultFileMode is the default permission of the files written by the console.
+const defaultFileMode os.FileMode = 0644
+
+// Storage is the backend used by the console to read and write files, such as config files and buffer dumps.
+// ReadFile must return an error for which errors.Is(err, os.ErrNotExist) holds if the file doesn't exist.
+// A custom storage lets platforms without a real file system, like js/wasm, persist configs elsewhere.
+type Storage interface {
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte, perm os.FileMode) error
+}
+
+// osStorage is the default storage, which uses the file system of the operating system.
+type osStorage struct{}
+
+func (osStorage) ReadFile(name string) ([]byte, error) {
+	return ioutil.ReadFile(name)
+}
+
+func (osStorage) WriteFile(name string, data []byte, perm os.FileMode) error {
+	return ioutil.WriteFile(name, data, perm)
+}
+
+// storageHolder wraps a Storage since atomic.Value can't store values of different types.
+type storageHolder struct {
+	s Storage
+}
+
+// SetStorage sets the backend used by Save, SaveMerge, Load, Validate and DumpBuffer.
+// Passing nil restores the default, which uses the file system of the operating system.
+func (c *Console) SetStorage(s Storage) {
+	if s == nil {
+		s = osStorage{}
+	}
+	c.storage.Store(storageHolder{s: s})
+}
+
+// files returns the storage of the console.
+func (c *Console) files() Storage {
+	if h, ok := c.storage.Load().(storageHolder); ok {
+		return h.s
+	}
+	return osStorage{}
+}
+
+// SetFileMode sets the permission of the files written by Save, SaveMerge and DumpBuffer, which is 0644 by default.
+// As with os.WriteFile, it only applies to new files and is subject to the umask. A zero mode restores the default.
+func (c *Console) SetFileMode(mode os.FileMode) {
+	atomic.StoreUint32(&c.fileMode, uint32(mode.Perm()))
+}
+
+// perm returns the permission of the files written by the console.
+func (c *Console) perm() os.FileMode {
+	if mode := atomic.LoadUint32(&c.fileMode); mode != 0 {
+		return os.FileMode(mode)
+	}
+	return defaultFileMode
+}
@@ -11,4 +11,163 @@ const (
 	errTypeMismatch        = "given value %v for variable %s is not of type %s"
 	errUnsupportedType     = "unsupported type %s"
 	errNilValue            = "value can't be nil"
+	errInvalidName         = "invalid variable name %q"
+	errNotStructPtr        = "expected a pointer to a struct, got %T"
+	errFieldUnexported     = "field %s is not exported"
+	errFieldUnsupported    = "field %s of type %s is not supported"
+	errRangeUnsupported    = "variable %s is not numeric"
+	errBadRange            = "invalid range [%v, %v]"
+	errOutOfRange          = "value %v for variable %s is out of range [%v, %v]"
+	errMaxDepth            = "maximum nesting depth of %d is exceeded"
+	errUnterminatedQuote   = "unterminated quote"
+	errVarsNotFound        = "variables %s don't exist"
+	errCmdTooLong          = "command is longer than %d characters"
+	errBadCondition        = "invalid condition, expected if <convar> <op> <value> then <command>"
+	errBadOperator         = "operator %s is not supported for type %s"
+	errReadOnly            = "variable %s is read-only"
+	errNoPrivilege         = "not enough privilege to execute %s"
+	errBadRequest          = "request must have exactly one of the cmd and get fields"
+	errBadPassword         = "wrong password"
+	errUnknownCheckpoint   = "checkpoint %d doesn't exist"
+	errNotNumeric          = "variable %s is not numeric"
+	errBadOption           = "value %v for variable %s is not one of: %s"
+	errBadEnumDefault      = "default value %s for variable %s is not one of its options"
+	errNoOptions           = "enum variable %s has no options"
+	errNotDefaultConVar    = "%s is not a default convar"
+	errNotFinite           = "value %v for variable %s is not a finite number"
+	errNumRange            = "value %s is out of the range of type %s"
+	errAmbiguous           = "command %s is ambiguous: %s"
+	errRequiresArg         = "command %s requires an argument"
+	errNilDefault          = "default value for convar %s cannot be nil"
+	errNotGreedy           = "variable %s is not a string func, so it can't be greedy"
+	errWriteOnly           = "variable %s is write-only"
+	errNoPassword          = "remote console requires a password"
+	errBadArgs             = "wrong number of arguments for %s"
+	errNotWriteOnly        = "variable %s must be write-only to hold a password"
 )
+
+// Strings holds the user-facing messages of a console, which can be replaced via SetStrings to localize it.
+// Messages are format strings that take the same arguments in the same order as the default ones.
+type Strings struct {
+	// Errors returned while executing commands and setting values.
+	ErrBadStringConversion string
+	ErrVarNotFound         string
+	ErrVarBadType          string
+	ErrTypeMismatch        string
+	ErrNilValue            string
+	ErrOutOfRange          string
+	ErrMaxDepth            string
+	ErrCmdTooLong          string
+	ErrBadCondition        string
+	ErrBadOperator         string
+	ErrReadOnly            string
+	ErrNoPrivilege         string
+	ErrBadPassword         string
+	ErrNotNumeric          string
+	ErrBadOption           string
+	ErrNotFinite           string
+	ErrNumRange            string
+	ErrAmbiguous           string
+	ErrRequiresArg         string
+	ErrWriteOnly           string
+	ErrNoPassword          string
+	ErrUnterminatedQuote   string
+	ErrUnsupportedType     string
+	ErrInvalidName         string
+	ErrNotStructPtr        string
+	ErrFieldUnexported     string
+	ErrFieldUnsupported    string
+	ErrRangeUnsupported    string
+	ErrBadRange            string
+	ErrBadArgs             string
+	ErrNotWriteOnly        string
+	ErrVarsNotFound        string
+	ErrUnknownCheckpoint   string
+	ErrBadRequest          string
+
+	// Descriptions of the convars registered by RegDefaultConVars.
+	DescConDump     string
+	DescConClear    string
+	DescVarResetAll string
+	DescVarReset    string
+	DescVarLoad     string
+	DescVarSave     string
+	DescVarList     string
+	DescBind        string
+	DescIf          string
+	DescHelp        string
+	DescAddVar      string
+	DescScaleVar    string
+	DescShowHidden  string
+
+	// Messages logged by the console and the convars registered by RegDefaultConVars.
+	MsgSaved      string
+	MsgLoaded     string
+	MsgReset      string
+	MsgNotBound   string
+	MsgDeprecated string
+	MsgUsage      string
+}
+
+var defaultStrings = Strings{
+	ErrBadStringConversion: errBadStringConversion,
+	ErrVarNotFound:         errVarNotFound,
+	ErrVarBadType:          errVarBadType,
+	ErrTypeMismatch:        errTypeMismatch,
+	ErrNilValue:            errNilValue,
+	ErrOutOfRange:          errOutOfRange,
+	ErrMaxDepth:            errMaxDepth,
+	ErrCmdTooLong:          errCmdTooLong,
+	ErrBadCondition:        errBadCondition,
+	ErrBadOperator:         errBadOperator,
+	ErrReadOnly:            errReadOnly,
+	ErrNoPrivilege:         errNoPrivilege,
+	ErrBadPassword:         errBadPassword,
+	ErrNotNumeric:          errNotNumeric,
+	ErrBadOption:           errBadOption,
+	ErrNotFinite:           errNotFinite,
+	ErrNumRange:            errNumRange,
+	ErrAmbiguous:           errAmbiguous,
+	ErrRequiresArg:         errRequiresArg,
+	ErrWriteOnly:           errWriteOnly,
+	ErrNoPassword:          errNoPassword,
+	ErrUnterminatedQuote:   errUnterminatedQuote,
+	ErrUnsupportedType:     errUnsupportedType,
+	ErrInvalidName:         errInvalidName,
+	ErrNotStructPtr:        errNotStructPtr,
+	ErrFieldUnexported:     errFieldUnexported,
+	ErrFieldUnsupported:    errFieldUnsupported,
+	ErrRangeUnsupported:    errRangeUnsupported,
+	ErrBadRange:            errBadRange,
+	ErrBadArgs:             errBadArgs,
+	ErrNotWriteOnly:        errNotWriteOnly,
+	ErrVarsNotFound:        errVarsNotFound,
+	ErrUnknownCheckpoint:   errUnknownCheckpoint,
+	ErrBadRequest:          errBadRequest,
+
+	DescConDump:     "Saves the console buffer to a file.",
+	DescConClear:    "Clears the console buffer.",
+	DescVarResetAll: "Resets all convars to their default values.",
+	DescVarReset:    "Resets given convar to its default value.",
+	DescVarLoad:     "Loads convars from a file, overwriting the ones that are already in the memory.",
+	DescVarSave:     "Saves convars to a file.",
+	DescVarList:     "Lists all convars with their description.",
+	DescBind:        "Binds a command to a key, or prints the command bound to a key.",
+	DescIf:          "Executes a command if a condition holds: if <convar> <op> <value> then <command>",
+	DescHelp:        "Prints the description and the usage of given convar.",
+	DescAddVar:      "Adds an amount to the value of given numeric convar.",
+	DescScaleVar:    "Multiplies the value of given numeric convar by a factor.",
+	DescShowHidden:  "Shows the hidden convars in the lists and suggestions.",
+
+	MsgSaved:      "%s is saved",
+	MsgLoaded:     "%s is loaded",
+	MsgReset:      "%s is reset",
+	MsgNotBound:   "%s is not bound",
+	MsgDeprecated: "%s is deprecated, use %s",
+	MsgUsage:      "usage: %s",
+}
+
+// DefaultStrings returns the default English strings of a console.
+func DefaultStrings() Strings {
+	return defaultStrings
+}
@@ -0,0 +1,107 @@
+// Copyright © 2020 Cosku Bas. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package convar
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSetStringsPartial(t *testing.T) {
+	c := NewConsole(100, LogError, "", "", "")
+	c.SetStrings(Strings{
+		ErrVarNotFound:       "die Variable %s existiert nicht",
+		ErrUnterminatedQuote: "Anführungszeichen nicht geschlossen",
+		ErrVarsNotFound:      "die Variablen %s existieren nicht",
+		ErrUnknownCheckpoint: "Checkpoint %d existiert nicht",
+		ErrBadRequest:        "ungültige Anfrage",
+	})
+	c.RegDefaultConVars()
+	c.RegConVar(NewConVar("cl_width", reflect.Int, false, "", 640, nil))
+
+	// Empty fields fall back to the defaults
+	if got, want := c.ConVar("con_dump").Desc(), defaultStrings.DescConDump; got != want {
+		t.Errorf("con_dump description = %q, want %q", got, want)
+	}
+	if _, err := c.ExecCmd("cl_width abc"); err == nil || !strings.HasPrefix(err.Error(), "can't convert") {
+		t.Errorf("cl_width abc: error = %v, want the default message", err)
+	}
+
+	errs := map[string]error{}
+	_, errs["not found"] = c.ExecCmd("cl_height 480")
+	_, errs["quote"] = c.ExecCmd(`"cl_width 800`)
+	_, errs["vars"] = c.ReadGroup("cl_width", "cl_height")
+	errs["checkpoint"] = c.Rollback(42)
+	for name, want := range map[string]string{
+		"not found":  "die Variable cl_height existiert nicht",
+		"quote":      "Anführungszeichen nicht geschlossen",
+		"vars":       "die Variablen cl_height existieren nicht",
+		"checkpoint": "Checkpoint 42 existiert nicht",
+	} {
+		if err := errs[name]; err == nil || err.Error() != want {
+			t.Errorf("%s: error = %v, want %q", name, err, want)
+		}
+	}
+	c.ExecCmd(`if cl_width == "640 then cl_width 1`)
+	if lines := c.BufferRaw(); len(lines) == 0 || lines[len(lines)-1] != "Anführungszeichen nicht geschlossen" {
+		t.Errorf("if with an unterminated quote logs %q, want the localized error", lines)
+	}
+	if got := string(c.HandleJSON([]byte(`{}`))); !strings.Contains(got, "ungültige Anfrage") {
+		t.Errorf("HandleJSON({}) = %s, want the localized error", got)
+	}
+
+	// The default console still uses English
+	if _, _, err := ParseCommand(`say "gg`); err == nil || err.Error() != errUnterminatedQuote {
+		t.Errorf("ParseCommand error = %v, want %q", err, errUnterminatedQuote)
+	}
+}
+
+func TestSetStringsCoversErrors(t *testing.T) {
+	// Every message is prefixed, so an error that isn't taken from the strings of the console stands out
+	var s Strings
+	v, def := reflect.ValueOf(&s).Elem(), reflect.ValueOf(defaultStrings)
+	for i := 0; i < v.NumField(); i++ {
+		v.Field(i).SetString("L: " + def.Field(i).String())
+	}
+	c := NewConsole(100, LogError, "", "", "")
+	c.SetStrings(s)
+	name := NewConVar("name", reflect.String, false, "", "", nil)
+	width := NewConVar("cl_width", reflect.Int, false, "", 640, nil)
+	c.RegConVar(name)
+	c.RegConVar(width)
+
+	var fields struct {
+		Bad int `convar:"bad name"`
+	}
+	var unexported struct {
+		hidden int `convar:"hidden"`
+	}
+	var unsupported struct {
+		List []int `convar:"list"`
+	}
+	errs := map[string]error{
+		"range unsupported": name.SetRange("a", "b"),
+		"range nil":         width.SetRange(nil, 10),
+		"range type":        width.SetRange(1.5, 10),
+		"bad range":         width.SetRange(10, 1),
+		"step unsupported":  name.SetStep("a"),
+		"step nil":          width.SetStep(nil),
+		"step type":         width.SetStep(1.5),
+		"map":               c.RegFromMap(map[string]interface{}{"bad name": 1}),
+		"map nil":           c.RegFromMap(map[string]interface{}{"x": nil}),
+		"map type":          c.RegFromMap(map[string]interface{}{"y": []int{}}),
+		"struct ptr":        c.RegStruct(fields),
+		"struct name":       c.RegStruct(&fields),
+		"struct unexported": c.RegStruct(&unexported),
+		"struct type":       c.RegStruct(&unsupported),
+	}
+	_, errs["exec"] = c.ExecCmd("cl_width 800 600")
+	for name, err := range errs {
+		if err == nil || !strings.HasPrefix(err.Error(), "L: ") {
+			t.Errorf("%s: error = %v, want a localized one", name, err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright © 2020 Cosku Bas. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package convar
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// SuppressCallbacks calls fn with the set/update callbacks of the convars suppressed, for ex. to load a preset
+// without triggering an expensive callback for every change. After fn returns, the callback of each convar changed
+// during fn is triggered once, in the order of their first change, with the value before the first change and the
+// final value. Convars whose final value equals the previous one are skipped, unless they are set via ForceSet.
+// Calls can be nested, the callbacks are triggered when the outermost call returns. The suppression applies to
+// changes made by other goroutines during fn too. Func convars are executed as usual.
+func (c *Console) SuppressCallbacks(fn func()) {
+	c.suppressLock.Lock()
+	if atomic.AddInt32(&c.suppress, 1) == 1 {
+		c.suppressOld = make(map[*ConVar]interface{})
+		c.suppressCvs = nil
+		c.suppressForce = make(map[*ConVar]bool)
+	}
+	c.suppressLock.Unlock()
+	defer c.endSuppress()
+	fn()
+}
+
+// endSuppress ends a SuppressCallbacks call, triggering the suppressed callbacks if it's the outermost one.
+func (c *Console) endSuppress() {
+	c.suppressLock.Lock()
+	if atomic.AddInt32(&c.suppress, -1) != 0 {
+		c.suppressLock.Unlock()
+		return
+	}
+	old, cvs, force := c.suppressOld, c.suppressCvs, c.suppressForce
+	c.suppressOld, c.suppressCvs, c.suppressForce = nil, nil, nil
+	c.suppressLock.Unlock()
+
+	for _, cv := range cvs {
+		if newVal := cv.value.Load(); newVal != old[cv] || force[cv] {
+			cv.changed(context.Background(), old[cv], newVal, force[cv])
+		}
+	}
+}
+
+// suppressed reports whether the callbacks are suppressed, in which case the change is recorded to be triggered
+// later. It is safe to call on a nil console.
+func (c *Console) suppressed(cv *ConVar, oldVal interface{}, force bool) bool {
+	if c == nil || atomic.LoadInt32(&c.suppress) == 0 {
+		return false
+	}
+	c.suppressLock.Lock()
+	defer c.suppressLock.Unlock()
+	if c.suppressOld == nil {
+		return false
+	}
+	if _, ok := c.suppressOld[cv]; !ok {
+		c.suppressOld[cv] = oldVal
+		c.suppressCvs = append(c.suppressCvs, cv)
+	}
+	if force {
+		c.suppressForce[cv] = true
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+// Copyright © 2020 Cosku Bas. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.md file.
+
+package convar
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// ParseCommand splits a command into its name and arguments the same way the console does.
+// Tokens are separated by whitespace and double quotes group a token that contains whitespace,
+// for ex. `bind f3 "say gg"` results in the name "bind" and the arguments "f3" and "say gg".
+// Within quotes, a backslash escapes a double quote or another backslash.
+// The name is empty if the command is empty or a comment. Note that names are case insensitive but
+// ParseCommand returns them in their original case.
+func ParseCommand(cmd string) (name string, args []string, err error) {
+	name, rest, err := splitCommand(cmd)
+	if err != nil || name == "" {
+		return "", nil, err
+	}
+	args, err = tokenize(rest)
+	if err != nil {
+		return "", nil, err
+	}
+	return name, args, nil
+}
+
+// splitCommand splits the name of a command from its raw remainder.
+// The name is empty if the command is empty or a comment.
+func splitCommand(cmd string) (name, rest string, err error) {
+	cmd = strings.TrimSpace(cmd)
+	if strings.HasPrefix(cmd, "#") {
+		return "", "", nil
+	}
+	return nextToken(cmd)
+}
+
+// unquoteValue returns the content of s if the whole of s is a single quoted token, otherwise s as it is.
+func unquoteValue(s string) string {
+	if strings.HasPrefix(s, "\"") {
+		if tokens, err := tokenize(s); err == nil && len(tokens) == 1 {
+			return tokens[0]
+		}
+	}
+	return s
+}
+
+// errQuote is returned by the tokenizer for an unterminated quote. Since the tokenizer has no console, consoles
+// replace it with their own message via Console.tokenErr.
+var errQuote = errors.New(errUnterminatedQuote)
+
+// nextToken splits the first token from s and returns it together with the raw remainder.
+// Tokens are separated by whitespace. A double quoted part of a token may contain whitespace,
+// and a backslash escapes a double quote or another backslash within it. The quotes are removed from the token.
+func nextToken(s string) (tok, rest string, err error) {
+	s = strings.TrimLeftFunc(s, unicode.IsSpace)
+	var (
+		b       strings.Builder
+		quoted  bool
+		escaped bool
+	)
+	for i, r := range s {
+		switch {
+		case escaped:
+			b.WriteRune(r)
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case !quoted && unicode.IsSpace(r):
+			return b.String(), strings.TrimSpace(s[i:]), nil
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if quoted {
+		return "", "", errQuote
+	}
+	return b.String(), "", nil
+}
+
+// tokenize splits s into tokens as described in nextToken.
+func tokenize(s string) ([]string, error) {
+	var tokens []string
+	for s = strings.TrimSpace(s); s != ""; {
+		tok, rest, err := nextToken(s)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, tok)
+		s = rest
+	}
+	return tokens, nil
+}
+
+// quote returns s as a single token that tokenize turns back into s.
+func quote(s string) string {
+	if s != "" && !strings.ContainsAny(s, "\"\\") && strings.IndexFunc(s, unicode.IsSpace) < 0 {
+		return s
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		if r == '"' || r == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('"')
+	return b.String()
+}